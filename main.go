@@ -20,6 +20,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	swr "github.com/gabereiser/swr"
@@ -37,5 +38,11 @@ under certain conditions; see LICENSE for details.
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(swr.Verify())
+	}
+	if len(os.Args) > 3 && os.Args[1] == "convert" {
+		os.Exit(swr.Convert(os.Args[2], os.Args[3]))
+	}
 	swr.Main()
 }
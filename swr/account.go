@@ -0,0 +1,274 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Accounts live in sqlite (data/game.db), characters stay in their yml files
+// under data/accounts. An account owns any number of characters through a
+// PlayerRef, and the Priv and Email on the account are shared by all of them.
+
+const (
+	RESET_TOKEN_TTL    = 30 * time.Minute
+	RESET_TOKEN_RESEND = 5 * time.Minute // no more than one mail this often
+)
+
+func player_path(name string) string {
+	name = strings.ToLower(name)
+	return fmt.Sprintf("data/accounts/%s/%s.yml", name[0:1], name)
+}
+
+// Find an account by its username, or by the name of one of its characters so
+// folks who only ever knew their character name can still log in.
+func account_find(name string) *Account {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+	account := new(Account)
+	// Find instead of First, a miss is not worth an error in the log
+	if DB().db.Preload("Characters").Where("username = ?", name).Limit(1).Find(account).RowsAffected == 1 {
+		return account
+	}
+	ref := new(PlayerRef)
+	if DB().db.Where("name = ?", name).Limit(1).Find(ref).RowsAffected != 1 {
+		return nil
+	}
+	return account_get(ref.AccountID)
+}
+
+func account_get(id uint) *Account {
+	account := new(Account)
+	if DB().db.Preload("Characters").Limit(1).Find(account, id).RowsAffected != 1 {
+		return nil
+	}
+	return account
+}
+
+func account_create(username string, password string, email string) *Account {
+	account := &Account{
+		Username:   strings.ToLower(username),
+		Password:   password_hash(password),
+		Email:      email,
+		Priv:       1,
+		Characters: make([]PlayerRef, 0),
+	}
+	if err := DB().db.Create(account).Error; err != nil {
+		ErrorCheck(err)
+		return nil
+	}
+	return account
+}
+
+// Is the name free for a new account or character? Account usernames and
+// character names share a namespace since either one gets you logged in.
+func account_name_free(name string, account *Account) bool {
+	name = strings.ToLower(name)
+	if Storage().HasPlayer(name) {
+		return false
+	}
+	other := account_find(name)
+	return other == nil || (account != nil && other.ID == account.ID)
+}
+
+// Checks the password, quietly upgrading an old hash while we have it in hand.
+func (a *Account) CheckPassword(password string) bool {
+	ok, rehash := password_verify(password, a.Password)
+	if ok && rehash {
+		log.Printf("Upgrading password hash for account %s", a.Username)
+		a.Password = password_hash(password)
+		a.Save()
+	}
+	return ok
+}
+
+func (a *Account) SetPassword(password string) {
+	a.Password = password_hash(password)
+	a.Save()
+}
+
+// Mails out a token that works in place of the password for a little while.
+func (a *Account) SendResetToken() error {
+	if time.Until(a.ResetExpires) > RESET_TOKEN_TTL-RESET_TOKEN_RESEND {
+		return Err("a token was sent a moment ago")
+	}
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+	body := sprintf("Somebody (hopefully you) asked to reset the password on your %s account %s.\n\n"+
+		"Log in as usual and type this token in place of your password:\n\n    %s\n\n"+
+		"It is good for %d minutes. If it wasn't you, just ignore this mail.\n",
+		Config().Name, a.Username, token, int(RESET_TOKEN_TTL.Minutes()))
+	if err := Mail().Send(a.Email, sprintf("%s password reset", Config().Name), body); err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(token))
+	a.ResetToken = hex.EncodeToString(sum[:])
+	a.ResetExpires = time.Now().Add(RESET_TOKEN_TTL)
+	a.Save()
+	log.Printf("Sent a password reset token for account %s", a.Username)
+	return nil
+}
+
+// Checks and uses up a reset token.
+func (a *Account) CheckResetToken(token string) bool {
+	if a.ResetToken == "" || time.Now().After(a.ResetExpires) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(token))))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(a.ResetToken)) != 1 {
+		return false
+	}
+	a.ResetToken = ""
+	a.ResetExpires = time.Time{}
+	a.Save()
+	return true
+}
+
+// l***@example.com, enough to know which mailbox to look in.
+func mask_email(email string) string {
+	at := strings.Index(email, "@")
+	if at < 1 {
+		return "***"
+	}
+	return email[0:1] + "***" + email[at:]
+}
+
+func (a *Account) Save() {
+	ErrorCheck(DB().db.Omit("Characters").Save(a).Error)
+}
+
+func (a *Account) AddCharacter(player *PlayerProfile) {
+	ref := PlayerRef{
+		AccountID: a.ID,
+		Name:      strings.ToLower(player.Char.Name),
+		Data:      player_path(player.Char.Name),
+	}
+	if err := DB().db.Create(&ref).Error; err != nil {
+		ErrorCheck(err)
+		return
+	}
+	a.Characters = append(a.Characters, ref)
+}
+
+// Loads one of the account's characters and hands it what it shares with its
+// alts.
+func (a *Account) LoadCharacter(ref PlayerRef) *PlayerProfile {
+	if !Storage().HasPlayer(ref.Name) {
+		log.Printf("Account %s is missing character %s", a.Username, ref.Name)
+		return nil
+	}
+	player := DB().LoadPlayer(ref.Name)
+	if player == nil {
+		return nil
+	}
+	a.Apply(player)
+	return player
+}
+
+func (a *Account) Apply(player *PlayerProfile) {
+	player.Account = a.ID
+	player.Email = a.Email
+	player.Priv = int(a.Priv)
+	player.Password = "" // the account has it now
+}
+
+// One-shot migration from the days of one password per character. The first
+// time we boot with an empty accounts table every player file gets grouped
+// into an account with the others that have the same email, password hash
+// and Priv. The email was never verified, so sharing one proves nothing, the
+// password does. Priv too, so no character comes out of it with more than its
+// own file gave it. The newest character names the account.
+func accounts_migrate() {
+	var count int64
+	DB().db.Model(&Account{}).Count(&count)
+	if count > 0 {
+		return
+	}
+	files, err := filepath.Glob("data/accounts/*/*.yml")
+	ErrorCheck(err)
+	if len(files) == 0 {
+		return
+	}
+	log.Printf("Migrating %d player files to accounts.", len(files))
+	groups := make(map[string][]*PlayerProfile)
+	order := make([]string, 0)
+	for _, file := range files {
+		player := DB().ReadPlayerData(file)
+		if player == nil {
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(player.Email))
+		key := fmt.Sprintf("%s %s %d", email, player.Password, player.Priv)
+		if email == "" || player.Password == "" {
+			key = "alone:" + strings.ToLower(player.Char.Name)
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], player)
+	}
+	accounts := 0
+	for _, key := range order {
+		players := groups[key]
+		sort.SliceStable(players, func(i, j int) bool {
+			return players[i].LastSeen.After(players[j].LastSeen)
+		})
+		newest := players[0]
+		account := &Account{
+			Username:   strings.ToLower(newest.Char.Name),
+			Password:   newest.Password,
+			Email:      newest.Email,
+			Priv:       1,
+			Characters: make([]PlayerRef, 0),
+		}
+		if newest.Priv > 1 {
+			account.Priv = uint(newest.Priv) // they all have the same
+		}
+		for _, p := range players {
+			account.Characters = append(account.Characters, PlayerRef{
+				Name: strings.ToLower(p.Char.Name),
+				Data: player_path(p.Char.Name),
+			})
+		}
+		if err := DB().db.Create(account).Error; err != nil {
+			log.Printf("Error migrating account %s: %v", account.Username, err)
+			continue
+		}
+		for _, p := range players {
+			account.Apply(p)
+			DB().SavePlayerData(p)
+		}
+		accounts++
+	}
+	log.Printf("Migrated %d player files into %d accounts.", len(files), accounts)
+}
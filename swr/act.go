@@ -38,9 +38,14 @@ func do_password(entity Entity, args ...string) {
 			player.Send("\r\nSyntax: password <oldpassword> <newpassword> <repeat newpassword>\r\n")
 		} else {
 			oldp := args[0]
-			if encrypt_string(oldp) == player.Password {
-				if strings.EqualFold(args[1], args[2]) {
-					player.Password = encrypt_string(args[1])
+			account := account_get(player.Account)
+			if account == nil {
+				player.Send("\r\n&RYou don't seem to have an account!&d\r\n")
+				return
+			}
+			if account.CheckPassword(oldp) {
+				if args[1] == args[2] {
+					account.SetPassword(args[1])
 					player.Send("\r\n&YPassword. Ok.&d\r\n")
 				} else {
 					player.Send("\r\n&RPassword Mis-match!&d\r\n")
@@ -60,20 +65,8 @@ func do_save(entity Entity, args ...string) {
 	}
 }
 func do_look(entity Entity, args ...string) {
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
 	if entity.IsPlayer() {
-		ch := entity.GetCharData()
 		player := entity.(*PlayerProfile)
-		if ch.State == ENTITY_STATE_DEAD {
-			return
-		}
-		if ch.State == ENTITY_STATE_SLEEPING || ch.State == ENTITY_STATE_UNCONSCIOUS {
-			entity.Send("\r\n&cIn your dreams?...&d\r\n")
-			return
-		}
 		if len(args) == 0 { // l or look with no args
 			roomId := entity.RoomId()
 			shipId := entity.ShipId()
@@ -84,14 +77,16 @@ func do_look(entity Entity, args ...string) {
 					entity.Send(fmt.Sprintf("\r\n%s\r\n",
 						MakeTitle(sprintf("%s [%d]", room.Name, room.Id),
 							ANSI_TITLE_STYLE_NORMAL,
-							ANSI_TITLE_ALIGNMENT_CENTER)))
+							ANSI_TITLE_ALIGNMENT_CENTER,
+							entity_width(entity))))
 				} else {
 					entity.Send(fmt.Sprintf("\r\n%s\r\n",
 						MakeTitle(room.Name,
 							ANSI_TITLE_STYLE_NORMAL,
-							ANSI_TITLE_ALIGNMENT_CENTER)))
+							ANSI_TITLE_ALIGNMENT_CENTER,
+							entity_width(entity))))
 				}
-				entity.Send(sprintf("&W%s&d\r\n\r\n", StitchParagraphs(telnet_encode(room.Desc), build_map(room))))
+				entity.Send(sprintf("&W%s&d\r\n\r\n", StitchParagraphs(WordWrap(room.Desc, entity_width(entity)-12), build_map(room), entity_width(entity))))
 				entity.Send("Exits: \r\n")
 				for dir, to_room_id := range room.Exits {
 					to_room := DB().GetRoom(to_room_id, shipId)
@@ -127,7 +122,7 @@ func do_look(entity Entity, args ...string) {
 				}
 				for _, e := range room.GetEntities() {
 					if e != entity {
-						entity.Send("&P%s&d\r\n", e.GetCharData().Name)
+						entity.Send("&P%s&d%s\r\n", e.GetCharData().Name, linkdead_tag(e))
 					}
 				}
 				if shipId > 0 {
@@ -137,8 +132,9 @@ func do_look(entity Entity, args ...string) {
 							entity.Send(fmt.Sprintf("\r\nThrough your ships viewscreen you see...\r\n\r\n%s\r\n",
 								MakeTitle(room.Name,
 									ANSI_TITLE_STYLE_NORMAL,
-									ANSI_TITLE_ALIGNMENT_CENTER)))
-							entity.Send(sprintf("&W%s&d\r\n", StitchParagraphs(telnet_encode(room.Desc), build_map(room))))
+									ANSI_TITLE_ALIGNMENT_CENTER,
+									entity_width(entity))))
+							entity.Send(sprintf("&W%s&d\r\n", StitchParagraphs(WordWrap(room.Desc, entity_width(entity)-12), build_map(room), entity_width(entity))))
 							for dir, to_room_id := range room.Exits {
 								to_room := DB().GetRoom(to_room_id, shipId)
 								if k, ok := room.ExitFlags[dir]; ok {
@@ -235,19 +231,9 @@ func do_down(entity Entity, args ...string) {
 	do_direction(entity, "down")
 }
 
+// Position and fighting are checked by do_command, see the direction
+// commands in commands.yml.
 func do_direction(entity Entity, direction string) {
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
-	if entity.GetCharData().State == ENTITY_STATE_SITTING {
-		entity.Send("\r\nYou are unable to move while sitting.\r\n")
-		return
-	}
-	if entity.IsFighting() {
-		entity.Send("\r\nYou are fighting!\r\n")
-		return
-	}
 	db := DB()
 	room := db.GetRoom(entity.RoomId(), entity.ShipId())
 	if !room.HasExit(direction) {
@@ -286,10 +272,10 @@ func do_direction(entity Entity, direction string) {
 						}
 					}
 				}
-				go room_prog_exec(entity, "leave", direction)
+				room_prog_exec(entity, "leave", direction)
 				entity.GetCharData().Room = to_room.Id
 				do_look(entity)
-				go room_prog_exec(entity, "enter", direction_reverse(direction))
+				room_prog_exec(entity, "enter", direction_reverse(direction))
 				for _, e := range to_room.GetEntities() {
 					if entity_unspeakable_state(e) {
 						continue
@@ -377,14 +363,6 @@ func do_sleep(entity Entity, args ...string) {
 }
 
 func do_open(entity Entity, args ...string) {
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
-	if entity.GetCharData().State == ENTITY_STATE_SITTING {
-		entity.Send("\r\nYou are unable to move while sitting.\r\n")
-		return
-	}
 	db := DB()
 	room := db.GetRoom(entity.RoomId(), entity.ShipId())
 	if len(args) == 0 {
@@ -400,14 +378,6 @@ func do_open(entity Entity, args ...string) {
 }
 
 func do_close(entity Entity, args ...string) {
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
-	if entity.GetCharData().State == ENTITY_STATE_SITTING {
-		entity.Send("\r\nYou are unable to move while sitting.\r\n")
-		return
-	}
 	db := DB()
 	room := db.GetRoom(entity.RoomId(), entity.ShipId())
 	if len(args) == 0 {
@@ -444,7 +414,7 @@ func do_get(entity Entity, args ...string) {
 			room.RemoveItem(item)
 			room.SendToOthers(entity, sprintf("\r\n&P%s&d picks up &Y%s&d.\r\n", ch.Name, item.GetData().Name))
 			entity.Send("\r\n&dYou pick up &Y%s&d.\r\n", item.GetData().Name)
-			go room_prog_exec(entity, "get", item) // indiana jones...
+			room_prog_exec(entity, "get", item) // indiana jones...
 			return
 		}
 	}
@@ -632,37 +602,25 @@ func do_drop(entity Entity, args ...string) {
 			}
 		}
 	}
-	go room_prog_exec(entity, "drop", item)
+	room_prog_exec(entity, "drop", item)
 }
 
 func do_statsys(entity Entity, args ...string) {
-	db := DB()
-	mobCount := 0
-	playerCount := 0
-	for _, e := range db.entities {
-		if e == nil {
-			continue
-		}
-		if !e.IsPlayer() {
-			mobCount++
-		} else {
-			playerCount++
-		}
-	}
+	stats := DB().Stats()
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	entity.Send("\r\n%s\r\n", MakeTitle("System Stats", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("\r\n%s\r\n", MakeTitle("System Stats", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("&G      System Name:&W %s\r\n", Config().Name)
-	entity.Send("&G    Total Systems: &W%-3d       &GTotal Areas: &W%-3d&d\r\n", len(db.starsystems), len(db.areas))
-	entity.Send("&G       Total Mobs: &W%-12d &GTotal Rooms: &W%-12d&d\r\n", mobCount, len(db.rooms))
-	entity.Send("&G      Total Ships: &W%-4d&d\r\n", len(db.ships))
-	entity.Send("\r\n%s\r\n", MakeTitle("OS", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("&G    Total Systems: &W%-3d       &GTotal Areas: &W%-3d&d\r\n", stats.Systems, stats.Areas)
+	entity.Send("&G       Total Mobs: &W%-12d &GTotal Rooms: &W%-12d&d\r\n", stats.Mobs, stats.Rooms)
+	entity.Send("&G      Total Ships: &W%-4d&d\r\n", stats.Ships)
+	entity.Send("\r\n%s\r\n", MakeTitle("OS", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("&Y           Name&d: %s\r\n", runtime.GOOS)
 	entity.Send("&Y           Arch&d: %s\r\n", runtime.GOARCH)
-	entity.Send("\r\n%s\r\n", MakeTitle("CPU", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("\r\n%s\r\n", MakeTitle("CPU", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("&Y          Cores&d: %d\r\n", runtime.NumCPU())
 	entity.Send("&Y  Total Threads&d: %d\r\n", runtime.NumGoroutine())
-	entity.Send("\r\n%s\r\n", MakeTitle("Memory", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("\r\n%s\r\n", MakeTitle("Memory", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("&Y Current Memory&d: %.4f mb\r\n", bytes_to_mb(m.Alloc))
 	entity.Send("&YReserved Memory&d: %.4f mb\r\n", bytes_to_mb(m.Sys))
 	entity.Send("&Y    Misc Memory&d: %.4f mb\r\n", bytes_to_mb(m.OtherSys))
@@ -31,10 +31,6 @@ func do_say(entity Entity, args ...string) {
 		return
 	}
 	speaker := entity.GetCharData()
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
 	if entity.IsPlayer() {
 		entity.Send("You say \"%s\"\n", words)
 	}
@@ -54,7 +50,7 @@ func do_say(entity Entity, args ...string) {
 			}
 		}
 	}
-	go room_prog_exec(entity, "say", words)
+	room_prog_exec(entity, "say", words)
 }
 
 func do_shout(entity Entity, args ...string) {
@@ -64,12 +60,9 @@ func do_shout(entity Entity, args ...string) {
 		entity.Send("\r\n&RShout what?&d\r\n")
 	}
 	speaker := entity.GetCharData()
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
 	if entity.IsPlayer() {
 		entity.Send("You shout \"%s\"!\n", words)
+		gmcp_comm_channel(entity, "shout", speaker.Name, words)
 	}
 	yell(entity, words, speaker.RoomId(), 0, make([]uint, 0))
 }
@@ -97,7 +90,9 @@ func yell(entity Entity, words string, roomId uint, dist uint, visited []uint) {
 			if ex != entity {
 				if ex.IsPlayer() {
 					listener := ex.GetCharData()
-					ex.Send("Someone shouts \"%s\"!\n", language_spoken(speaker, listener, words))
+					heard := language_spoken(speaker, listener, words)
+					ex.Send("Someone shouts \"%s\"!\n", heard)
+					gmcp_comm_channel(ex, "shout", "Someone", heard)
 				} else {
 					ex.Send("Someone shouts \"%s\"!\n", words)
 				}
@@ -118,10 +113,6 @@ func yell(entity Entity, words string, roomId uint, dist uint, visited []uint) {
 func do_emote(entity Entity, args ...string) {
 	emote := strings.Join(args, " ")
 	speaker := entity.GetCharData()
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
 	speaker.GetRoom().SendToRoom(sprintf("&d%s %s&d\r\n", speaker.Name, emote))
 }
 func do_say_comlink(entity Entity, args ...string) {
@@ -129,12 +120,8 @@ func do_say_comlink(entity Entity, args ...string) {
 	words = strings.TrimSpace(words)
 	speaker := entity.GetCharData()
 	speaker_freq := entity.(*PlayerProfile).Frequency
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
 	if words == "" {
-		entity.Send("\r\n%s\r\n", MakeTitle("Comlink Status", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+		entity.Send("\r\n%s\r\n", MakeTitle("Comlink Status", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 		entity.Send("&GComlink&d: %-32s\r\n\r\n", "PIC//113 Kuat Systems Intercom")
 		entity.Send("&G----[&W%s&G]----&d\r\n", MakeTunerBar(speaker_freq, 50))
 		entity.Send("&G Freq&d: &Y%s mhz&d\r\n", speaker_freq)
@@ -142,9 +129,9 @@ func do_say_comlink(entity Entity, args ...string) {
 	}
 	if entity.IsPlayer() {
 		entity.Send("You're comlink hums after you say &W\"%s\"&d\r\n", words)
+		gmcp_comm_channel(entity, "comlink", speaker.Name, words)
 	}
-	db := DB()
-	for _, ex := range db.entities {
+	for _, ex := range DB().Entities() {
 		if ex == nil {
 			continue
 		}
@@ -156,7 +143,9 @@ func do_say_comlink(entity Entity, args ...string) {
 				listener := ex.GetCharData()
 				listener_freq := ex.(*PlayerProfile).Frequency
 				if listener_freq == speaker_freq {
-					ex.Send("&CYou're comlink crackles to life with a voice that says...&d\r\n\"&W%s&Y:&d %s\"\r\n", speaker.Name, language_spoken(speaker, listener, words))
+					heard := language_spoken(speaker, listener, words)
+					ex.Send("&CYou're comlink crackles to life with a voice that says...&d\r\n\"&W%s&Y:&d %s\"\r\n", speaker.Name, heard)
+					gmcp_comm_channel(ex, "comlink", speaker.Name, heard)
 				}
 			}
 		}
@@ -165,8 +154,7 @@ func do_say_comlink(entity Entity, args ...string) {
 
 //lint:ignore U1000 useful code
 func do_broadcast_comlink(freq string, message string) {
-	db := DB()
-	for _, ex := range db.entities {
+	for _, ex := range DB().Entities() {
 		if ex == nil {
 			continue
 		}
@@ -185,10 +173,6 @@ func do_broadcast_comlink(freq string, message string) {
 func do_tune_frequency(entity Entity, args ...string) {
 	if entity.IsPlayer() {
 		player := entity.(*PlayerProfile)
-		if entity_unspeakable_state(entity) {
-			entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-			return
-		}
 		if len(args) > 0 {
 			freq, err := strconv.ParseFloat(args[0], 32)
 			if err != nil {
@@ -219,10 +203,6 @@ func do_speak(entity Entity, args ...string) {
 		entity.Send("\r\n&CSyntax: speak <language>&d\r\n")
 		return
 	}
-	if entity_unspeakable_state(entity) {
-		entity.Send("\r\n&dYou are %s.&d\r\n", entity_unspeakable_reason(entity))
-		return
-	}
 	ch := entity.GetCharData()
 	language := language_get_by_name(args[0])
 	if language != nil {
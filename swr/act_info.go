@@ -25,18 +25,21 @@ import (
 
 func do_quit(entity Entity, args ...string) {
 	if entity.IsPlayer() {
-		if entity.IsFighting() {
-			entity.Send("\r\n&RYou can't quit while fighting!&d\r\n")
-			return
-		}
 		player := entity.(*PlayerProfile)
 		DB().SavePlayerData(player)
 		entity.Send("\r\n&CThe world slowly fades away as you close your eyes and leave the game...&d\r\n\r\n")
 		entity.GetCharData().State = ENTITY_STATE_SLEEPING
 		ScheduleFunc(func() {
-			player.Send("\r\n%s Thank you for playing! %s\r\n", EMOJI_ALERT, EMOJI_ALERT)
-			time.Sleep(100 * time.Millisecond)
-			player.Client.Close()
+			// out of the world first, so the hang up isn't taken for a dropped link
+			DB().RemoveEntity(player)
+			if client := player.Client; client != nil {
+				// not on the game loop, it'd hold up everybody
+				go func() {
+					client.Sendf("\r\n%s Thank you for playing! %s\r\n", EMOJI_ALERT, EMOJI_ALERT)
+					time.Sleep(100 * time.Millisecond)
+					client.Close()
+				}()
+			}
 		}, false, 1)
 	}
 }
@@ -46,22 +49,21 @@ func do_qui(entity Entity, args ...string) {
 }
 
 func do_who(entity Entity, args ...string) {
-	db := DB()
 	total := 0
 	entity.Send("\r\n")
-	entity.Send(MakeTitle("Who", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER))
-	for _, e := range db.entities {
+	entity.Send(MakeTitle("Who", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
+	for _, e := range DB().Entities() {
 		if e == nil {
 			continue
 		}
 		if e.IsPlayer() {
 			player := e.(*PlayerProfile)
-			entity.Send(sprintf("&W%-67s&G [ &WLevel %2d&G ]\r\n", player.Char.Title, player.Char.Level))
+			entity.Send(sprintf("&W%s&G [ &WLevel %2d&G ]%s\r\n", pad_right(player.Char.Title, entity_width(entity)-16), player.Char.Level, linkdead_tag(player)))
 			total++
 		}
 	}
 	entity.Send("\r\n")
-	entity.Send(MakeTitle(sprintf("%d Online", total), ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_RIGHT))
+	entity.Send(MakeTitle(sprintf("%d Online", total), ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_RIGHT, entity_width(entity)))
 	entity.Send("\r\n")
 }
 
@@ -69,42 +71,57 @@ func do_score(entity Entity, args ...string) {
 	if entity.IsPlayer() {
 		player := entity.(*PlayerProfile)
 		char := player.Char
-		player.Send("\r\n&c╒═══( &W%-16s&c )═══════════════════╕&d\r\n", char.Name)
-		player.Send("&c│ Title: &G%-25s&c         │&d▒\r\n", char.Title)
-		player.Send("&c│  Race: &G%-25s&c         │&d▒\r\n", char.Race)
-		player.Send("&c│ Level: &G%-25d&c         │&d▒\r\n", char.Level)
-		player.Send("&c├─( Stats )────────────────────────────────┤&d▒\r\n")
-		player.Send("&c│ STR: &G%-2d&c               XP: &G%-14d&c │&d▒\r\n", char.Stats[0], char.XP)
-		player.Send("&c│ INT: &G%-2d&c         NEXT LVL: &G%-14d&c │&d▒\r\n", char.Stats[1], get_xp_for_level(char.Level))
-		player.Send("&c│ DEX: &G%-2d&c            MONEY: &G%-14d&c │&d▒\r\n", char.Stats[2], char.Gold)
-		player.Send("&c│ WIS: &G%-2d&c             BANK: &G%-14d&c │&d▒\r\n", char.Stats[3], char.Bank)
-		player.Send("&c│ CON: &G%-2d&c                                  │&d▒\r\n", char.Stats[4])
-		player.Send("&c│ CHA: &G%-2d&c                                  │&d▒\r\n", char.Stats[5])
-		player.Send("&c╞══════════════════════════════════════════╡&d▒\r\n")
-		player.Send("&c│ Weight: &G%3d kg&p(%4d kg)&c                  │&d▒\r\n", char.CurrentWeight(), char.MaxWeight())
-		player.Send("&c│ Inventory: &G%3d&p(%3d)&c                      │&d▒\r\n", char.CurrentInventoryCount(), char.MaxInventoryCount())
-		player.Send("&c│ Kills: &G%-5d    &cPlayer Kills: &G%-5d&c      │&d▒\r\n", player.Kills, player.PKills)
-		player.Send("&c├─( Equipment )────────────────────────────┤▒&d\r\n")
-		player.Send("&c│       Head: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "head"))
-		player.Send("&c│      Torso: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "torso"))
-		player.Send("&c│      Waist: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "waist"))
-		player.Send("&c│       Legs: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "legs"))
-		player.Send("&c│       Feet: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "feet"))
-		player.Send("&c│      Hands: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "hands"))
-		player.Send("&c│                                          │&d▒\r\n")
-		player.Send("&c│     &RWeapon: &d%-20s&c         │&d▒\r\n", entity_get_equipment_for_slot(player, "weapon"))
-		player.Send("&c│                                          │&d▒\r\n")
-		player.Send("&c├──( Skills )──────────────────────────────┤&d▒\r\n")
+		// the box grows with the terminal, but never narrower than it always was
+		inner := entity_width(entity) - 4
+		if inner < 42 {
+			inner = 42
+		}
+		if inner > 76 {
+			inner = 76
+		}
+		row := func(format string, any ...interface{}) {
+			player.Send("&c│%s&c│&d▒\r\n", pad_right(sprintf(format, any...), inner))
+		}
+		stats := []string{"STR", "INT", "DEX", "WIS", "CON", "CHA"}
+		stat := func(n int, label string, value interface{}) {
+			row("%s&c%9s &G%v", pad_right(sprintf(" %s: &G%-2d", stats[n], char.Stats[n]), inner-25), label, value)
+		}
+		player.Send("\r\n&c%s&d\r\n", box_rule("╒", "═", "╕", 3, "&W"+char.Name+"&c", inner))
+		row(" Title: &G%s", char.Title)
+		row("  Race: &G%s", char.Race)
+		row(" Level: &G%d", char.Level)
+		player.Send("&c%s&d▒\r\n", box_rule("├", "─", "┤", 1, "Stats", inner))
+		stat(0, "XP:", char.XP)
+		stat(1, "NEXT LVL:", get_xp_for_level(char.Level))
+		stat(2, "MONEY:", char.Gold)
+		stat(3, "BANK:", char.Bank)
+		row(" CON: &G%-2d&c", char.Stats[4])
+		row(" CHA: &G%-2d&c", char.Stats[5])
+		player.Send("&c%s&d▒\r\n", box_rule("╞", "═", "╡", 0, "", inner))
+		row(" Weight: &G%3d kg&p(%4d kg)", char.CurrentWeight(), char.MaxWeight())
+		row(" Inventory: &G%3d&p(%3d)", char.CurrentInventoryCount(), char.MaxInventoryCount())
+		row(" Kills: &G%-5d    &cPlayer Kills: &G%-5d", player.Kills, player.PKills)
+		player.Send("&c%s&d▒\r\n", box_rule("├", "─", "┤", 1, "Equipment", inner))
+		row("       Head: &d%s", entity_get_equipment_for_slot(player, "head"))
+		row("      Torso: &d%s", entity_get_equipment_for_slot(player, "torso"))
+		row("      Waist: &d%s", entity_get_equipment_for_slot(player, "waist"))
+		row("       Legs: &d%s", entity_get_equipment_for_slot(player, "legs"))
+		row("       Feet: &d%s", entity_get_equipment_for_slot(player, "feet"))
+		row("      Hands: &d%s", entity_get_equipment_for_slot(player, "hands"))
+		row("")
+		row("     &RWeapon: &d%s", entity_get_equipment_for_slot(player, "weapon"))
+		row("")
+		player.Send("&c%s&d▒\r\n", box_rule("├", "─", "┤", 2, "Skills", inner))
 		for s, v := range char.Skills {
-			player.Send("&c│ &w%-25s&c          &w%3d&c   │&d▒\r\n", s, v)
+			row(" &w%s&w%3d", pad_right(s, inner-7), v)
 		}
-		player.Send("&c├──( Languages )───────────────────────────┤&d▒\r\n")
+		player.Send("&c%s&d▒\r\n", box_rule("├", "─", "┤", 2, "Languages", inner))
 		for s, v := range char.Languages {
-			player.Send("&c│ &w%-25s&c          &w%3d&c   │&d▒\r\n", s, v)
+			row(" &w%s&w%3d", pad_right(s, inner-7), v)
 		}
-		player.Send("&c│   &cSpeaking: &w%-20s&c         │&d▒\r\n", char.Speaking)
-		player.Send("&c└──────────────────────────────────────────┘&d▒\r\n")
-		player.Send(" ▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒▒\r\n")
+		row("   &cSpeaking: &w%s", char.Speaking)
+		player.Send("&c%s&d▒\r\n", box_rule("└", "─", "┘", 0, "", inner))
+		player.Send(" %s\r\n", strings.Repeat("▒", inner+2))
 	}
 }
 
@@ -125,7 +142,7 @@ func do_inventory(entity Entity, args ...string) {
 
 func do_levels(entity Entity, args ...string) {
 	ch := entity.GetCharData()
-	entity.Send("\r\n%s\r\n", MakeTitle("Levels / Experience", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("\r\n%s\r\n", MakeTitle("Levels / Experience", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("&YLevel: &W%3d&Y Exp: &W%d&Y/&W%d&d\r\n\r\n", ch.Level, ch.XP, get_xp_for_level(ch.Level))
 	level := ch.Level
 	if level > 100-5 {
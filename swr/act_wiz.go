@@ -203,7 +203,7 @@ func do_room_stat(entity Entity, args ...string) {
 		s := DB().GetShip(shipId)
 		ship = s.GetData().Name + " (" + s.GetData().Type + ")"
 	}
-	entity.Send("\r\n%s\r\n", MakeTitle("Room Stat", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("\r\n%s\r\n", MakeTitle("Room Stat", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("     &GName: &W\"%s\"&d\r\n", room.Name)
 	entity.Send("     &GVNum: &W%-7d &GShip: &W%s&d\r\n", room.Id, ship)
 	entity.Send("     &GArea: &W%s&d\r\n", room.Area.Name)
@@ -447,7 +447,7 @@ func do_item_create(entity Entity, args ...string) {
 	}
 	item.Filename = sprintf("data/items/%s/%s.yml", strings.ToLower(strings.ReplaceAll(room.Area.Name, " ", "")), strings.ToLower(filename))
 	DB().SaveItem(item)
-	DB().LoadItem(item.Filename)
+	ErrorCheck(DB().LoadItem(item.Filename))
 	entity.Send("\r\n&YObject Create. Ok.&d\r\n")
 	room.AddItem(item_clone(item))
 }
@@ -589,7 +589,7 @@ func do_item_stat(entity Entity, args ...string) {
 	item := room.FindItem(args[0])
 	if item != nil {
 		i := item.GetData()
-		entity.Send("\r\n%s\r\n", MakeTitle("Object Stats", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+		entity.Send("\r\n%s\r\n", MakeTitle("Object Stats", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 		entity.Send("&GFilename: &W%s&d\r\n", i.Filename)
 		entity.Send("&GID: &W%-9d &GOID: &W%-9d&d\r\n", i.Id, i.OId)
 		entity.Send("&GName: &W%s&d\r\n", i.Name)
@@ -632,7 +632,7 @@ func do_item_find(entity Entity, args ...string) {
 func do_room_find(entity Entity, args ...string) {
 	if len(args) == 0 {
 		room := entity.GetRoom()
-		entity.Send("\r\n%s\r\n", MakeTitle("Rooms", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+		entity.Send("\r\n%s\r\n", MakeTitle("Rooms", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 		rlist := make([]string, 0)
 		for _, r := range room.Area.Rooms {
 			if r.Name == "A void" {
@@ -727,7 +727,7 @@ func do_mob_create(entity Entity, args ...string) {
 	mob.Ship = room.ship
 
 	DB().SaveMob(mob)
-	DB().LoadMob(mob.Filename)
+	ErrorCheck(DB().LoadMob(mob.Filename))
 	DB().SpawnEntity(mob)
 	entity.Send("\r\n&YMob Create. Ok.&d\r\n")
 }
@@ -790,14 +790,10 @@ func do_mob_set(entity Entity, args ...string) {
 	case "desc":
 		tch.Desc = consolify(strings.TrimSpace(strings.Join(args[2:], " ")))
 	case "race":
-		found := false
-		for _, r := range race_list {
-			if strings.EqualFold(r, args[2]) {
-				found = true
-				tch.Race = r
-			}
-		}
-		if !found {
+		r := race_get(strings.Join(args[2:], " "))
+		if r != nil {
+			tch.Race = r.Name
+		} else {
 			entity.Send("\r\n&RInvalid race.&d\r\n")
 			return
 		}
@@ -960,7 +956,7 @@ func do_mob_set(entity Entity, args ...string) {
 	}
 	tch.Id = tch.OId // make it an original mob. Not a clone.
 	DB().SaveMob(tch)
-	DB().LoadMob(tch.Filename)
+	ErrorCheck(DB().LoadMob(tch.Filename))
 	entity.Send("\r\n&YMob Set. Ok.&d\r\n")
 }
 
@@ -1057,7 +1053,7 @@ func do_mob_stat(entity Entity, args ...string) {
 		return
 	}
 	tch := target.GetCharData()
-	entity.Send("\r\n%s\r\n", MakeTitle("Mob Stats", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+	entity.Send("\r\n%s\r\n", MakeTitle("Mob Stats", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 	entity.Send("&GFilename: &W%s&d\r\n", tch.Filename)
 	entity.Send("&GID: &W%d &GOID: &W%d&d\r\n", tch.Id, tch.OId)
 	entity.Send("&GName: &W%-26s &GLevel: &W%d&d\r\n", tch.Name, tch.Level)
@@ -1086,7 +1082,7 @@ func do_mob_stat(entity Entity, args ...string) {
 
 func do_mob_find(entity Entity, args ...string) {
 	if len(args) == 0 {
-		entity.Send("\r\n%s\r\n", MakeTitle("Mobs", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT))
+		entity.Send("\r\n%s\r\n", MakeTitle("Mobs", ANSI_TITLE_STYLE_SYSTEM, ANSI_TITLE_ALIGNMENT_LEFT, entity_width(entity)))
 		c := 0
 		for _, mob := range DB().mobs {
 			r := mob.GetCharData()
@@ -1273,7 +1269,6 @@ func do_ship_create(entity Entity, args ...string) {
 		ship: ship.Id,
 	}
 	DB().SaveShip(ship)
-	DB().LoadShip(sprintf("data/ships/%s.yml", ship.Name))
 	DB().SpawnShip(ship)
 
 	entity.Send("\r\n&YShip Create. Ok.&d\r\n")
@@ -1309,7 +1304,7 @@ func do_ship_remove(entity Entity, args ...string) {
 		return
 	}
 	DB().RemoveShip(ship)
-	e := os.Remove(sprintf("data/ships/%s.yml", strings.ToLower(strings.ReplaceAll(ship.GetData().Name, " ", "_"))))
+	e := Storage().DeleteShip(ship.GetData().Name)
 	ErrorCheck(e)
 	if prototype {
 		DB().RemoveShipPrototype(ship)
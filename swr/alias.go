@@ -0,0 +1,282 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A line a player types can turn into several commands before the game loop
+// runs them: aliases (`alias kk kill $1`), stacking (`n;n;e;look`) and
+// speedwalks (`3n2e`). The expanded commands wait on the player and run like
+// anything else they typed, a couple a pulse and subject to lag.
+
+const (
+	ALIAS_MAX        = 50              // aliases per player
+	ALIAS_DEPTH      = 8               // aliases calling aliases, no deeper than this
+	ALIAS_EXPAND_MAX = INPUT_QUEUE_MAX // commands one line can turn into
+	ALIAS_LENGTH     = 256             // longest alias body
+	COMMAND_SEP      = ";"
+)
+
+// Directions a speedwalk can use, and at least one count so plain `n` or `ne`
+// are still commands.
+var speedwalk_re = regexp.MustCompile(`^([0-9]*[nsewud])+$`)
+var speedwalk_step_re = regexp.MustCompile(`([0-9]*)([nsewud])`)
+
+var speedwalk_dirs = map[string]string{
+	"n": "north",
+	"s": "south",
+	"e": "east",
+	"w": "west",
+	"u": "up",
+	"d": "down",
+}
+
+// The next command for the player to run, from what's left of their last
+// expanded line or else the next line they typed. Game loop only.
+func (p *PlayerProfile) NextCommand() (string, bool) {
+	for {
+		if len(p.pending) > 0 {
+			input := p.pending[0]
+			p.pending = p.pending[1:]
+			return input, true
+		}
+		if p.Client == nil {
+			return "", false
+		}
+		line, ok := p.Client.Input().Pop()
+		if !ok {
+			return "", false
+		}
+		p.pending = command_expand(p, line)
+	}
+}
+
+// Throws away whatever is still waiting from an expanded line.
+func (p *PlayerProfile) ClearPending() {
+	p.pending = nil
+}
+
+// Expands a typed line into the commands to run.
+func command_expand(player *PlayerProfile, line string) []string {
+	ret := make([]string, 0)
+	if strings.TrimSpace(line) == "" {
+		return ret
+	}
+	if !command_expand_line(player, line, nil, &ret) && len(ret) >= ALIAS_EXPAND_MAX {
+		player.Send("\r\n&RThat expands into too many commands (%d at most), the rest were dropped.&d\r\n", ALIAS_EXPAND_MAX)
+	}
+	return ret
+}
+
+// inside is the aliases we're already in the middle of. One of those in its own
+// body (`alias look look auto`) is the real command, not the alias again.
+// false once we hit the cap or ALIAS_DEPTH.
+func command_expand_line(player *PlayerProfile, line string, inside []string, ret *[]string) bool {
+	for _, part := range command_split(line) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		args := strings.Fields(part)
+		name := strings.ToLower(args[0])
+		if body, ok := player.Aliases[name]; ok && !alias_inside(inside, name) {
+			if len(inside) >= ALIAS_DEPTH {
+				player.Send("\r\n&RAlias &W%s&R is too many aliases deep, stopping.&d\r\n", args[0])
+				return false
+			}
+			// a \; in the arguments was already split on once, keep it whole
+			for i := range args {
+				args[i] = strings.ReplaceAll(args[i], COMMAND_SEP, "\\"+COMMAND_SEP)
+			}
+			if !command_expand_line(player, alias_substitute(body, args[1:]), append(inside[:len(inside):len(inside)], name), ret) {
+				return false
+			}
+			continue
+		}
+		if steps := speedwalk_expand(part); steps != nil {
+			for _, step := range steps {
+				if len(*ret) >= ALIAS_EXPAND_MAX {
+					return false
+				}
+				*ret = append(*ret, step)
+			}
+			continue
+		}
+		if len(*ret) >= ALIAS_EXPAND_MAX {
+			return false
+		}
+		*ret = append(*ret, part)
+	}
+	return true
+}
+
+func alias_inside(inside []string, name string) bool {
+	for _, n := range inside {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Splits on the separator, a \; stays a plain ; so you can still say one.
+func command_split(line string) []string {
+	ret := make([]string, 0)
+	cur := ""
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == COMMAND_SEP[0] {
+			cur += COMMAND_SEP
+			i++
+			continue
+		}
+		if line[i] == COMMAND_SEP[0] {
+			ret = append(ret, cur)
+			cur = ""
+			continue
+		}
+		cur += line[i : i+1]
+	}
+	return append(ret, cur)
+}
+
+// $1..$9 are the arguments, $* all of them. A body without any gets the
+// arguments tacked on the end.
+func alias_substitute(body string, args []string) string {
+	if !strings.Contains(body, "$") {
+		if len(args) > 0 {
+			return body + " " + strings.Join(args, " ")
+		}
+		return body
+	}
+	ret := ""
+	for i := 0; i < len(body); i++ {
+		if body[i] == '$' && i+1 < len(body) {
+			c := body[i+1]
+			if c == '*' {
+				ret += strings.Join(args, " ")
+				i++
+				continue
+			}
+			if c >= '1' && c <= '9' {
+				n := int(c - '1')
+				if n < len(args) {
+					ret += args[n]
+				}
+				i++
+				continue
+			}
+		}
+		ret += body[i : i+1]
+	}
+	return ret
+}
+
+// 3n2e is north north north east east, nil if it isn't a speedwalk.
+func speedwalk_expand(str string) []string {
+	str = strings.ToLower(str)
+	if !speedwalk_re.MatchString(str) || !strings.ContainsAny(str, "0123456789") {
+		return nil
+	}
+	ret := make([]string, 0)
+	for _, m := range speedwalk_step_re.FindAllStringSubmatch(str, -1) {
+		count := 1
+		if m[1] != "" {
+			count, _ = strconv.Atoi(m[1])
+		}
+		for i := 0; i < count; i++ {
+			if len(ret) >= ALIAS_EXPAND_MAX {
+				return append(ret, speedwalk_dirs[m[2]]) // one over, so the cap warns
+			}
+			ret = append(ret, speedwalk_dirs[m[2]])
+		}
+	}
+	return ret
+}
+
+func do_alias(entity Entity, args ...string) {
+	if entity == nil || !entity.IsPlayer() {
+		return
+	}
+	player := entity.(*PlayerProfile)
+	if len(args) == 0 || args[0] == "" {
+		if len(player.Aliases) == 0 {
+			entity.Send("\r\n&GYou have no aliases. Try &Walias kk kill $1&G.&d\r\n")
+			return
+		}
+		names := make([]string, 0, len(player.Aliases))
+		for name := range player.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		entity.Send("\r\n%s\r\n", MakeTitle("Aliases", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
+		for _, name := range names {
+			entity.Send("&W%-12s &g%s&d\r\n", name, player.Aliases[name])
+		}
+		return
+	}
+	name := strings.ToLower(args[0])
+	if len(args) == 1 {
+		if body, ok := player.Aliases[name]; ok {
+			entity.Send("\r\n&W%s&G is aliased to &W%s&d\r\n", name, body)
+		} else {
+			entity.Send("\r\n&GYou have no alias &W%s&G.&d\r\n", name)
+		}
+		return
+	}
+	if name == "alias" || name == "unalias" || strings.ContainsAny(name, COMMAND_SEP+"$\\") || speedwalk_expand(name) != nil {
+		entity.Send("\r\n&RYou can't alias that.&d\r\n")
+		return
+	}
+	body := strings.Join(args[1:], " ")
+	if len(body) > ALIAS_LENGTH {
+		entity.Send("\r\n&RThat alias is too long, %d characters at most.&d\r\n", ALIAS_LENGTH)
+		return
+	}
+	if player.Aliases == nil {
+		player.Aliases = make(map[string]string)
+	}
+	if _, ok := player.Aliases[name]; !ok && len(player.Aliases) >= ALIAS_MAX {
+		entity.Send("\r\n&RYou already have %d aliases, unalias one first.&d\r\n", ALIAS_MAX)
+		return
+	}
+	player.Aliases[name] = body
+	entity.Send("\r\n&W%s&G is now aliased to &W%s&d\r\n", name, body)
+}
+
+func do_unalias(entity Entity, args ...string) {
+	if entity == nil || !entity.IsPlayer() {
+		return
+	}
+	player := entity.(*PlayerProfile)
+	if len(args) == 0 || args[0] == "" {
+		entity.Send("\r\n&GUnalias what?&d\r\n")
+		return
+	}
+	name := strings.ToLower(args[0])
+	if _, ok := player.Aliases[name]; !ok {
+		entity.Send("\r\n&GYou have no alias &W%s&G.&d\r\n", name)
+		return
+	}
+	delete(player.Aliases, name)
+	entity.Send("\r\n&GAlias &W%s&G removed.&d\r\n", name)
+}
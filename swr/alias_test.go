@@ -0,0 +1,141 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCommandSplit(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{"look", []string{"look"}},
+		{"n;n;e;look", []string{"n", "n", "e", "look"}},
+		{`say hi\; there;bow`, []string{"say hi; there", "bow"}},
+		{"say a \\ b", []string{"say a \\ b"}},
+		{";;", []string{"", "", ""}},
+		{"say trailing\\", []string{"say trailing\\"}},
+	}
+	for _, c := range cases {
+		if got := command_split(c.line); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %q, wanted %q", c.line, got, c.want)
+		}
+	}
+}
+
+func TestAliasSubstitute(t *testing.T) {
+	cases := []struct {
+		body string
+		args []string
+		want string
+	}{
+		{"kill $1", []string{"trooper"}, "kill trooper"},
+		{"kill $1", nil, "kill "},
+		{"give $2 $1", []string{"luke", "saber"}, "give saber luke"},
+		{"say $*", []string{"hello", "there"}, "say hello there"},
+		{"say $* and $1", []string{"a", "b"}, "say a b and a"},
+		{"look", []string{"north"}, "look north"},
+		{"look", nil, "look"},
+		{"say $9 costs $", []string{"x"}, "say  costs $"},
+		{"say $0", []string{"x"}, "say $0"},
+	}
+	for _, c := range cases {
+		if got := alias_substitute(c.body, c.args); got != c.want {
+			t.Errorf("%q %q: got %q, wanted %q", c.body, c.args, got, c.want)
+		}
+	}
+}
+
+func TestSpeedwalkExpand(t *testing.T) {
+	cases := []struct {
+		str  string
+		want []string
+	}{
+		{"3n2e", []string{"north", "north", "north", "east", "east"}},
+		{"2Nu", []string{"north", "north", "up"}},
+		{"n", nil},
+		{"ne", nil},
+		{"3x", nil},
+		{"look", nil},
+		{"0n1s", []string{"south"}},
+	}
+	for _, c := range cases {
+		if got := speedwalk_expand(c.str); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %q, wanted %q", c.str, got, c.want)
+		}
+	}
+	// one over the cap, so command_expand knows to say so
+	if got := speedwalk_expand("999n"); len(got) != ALIAS_EXPAND_MAX+1 {
+		t.Errorf("999n: %d steps, wanted %d", len(got), ALIAS_EXPAND_MAX+1)
+	}
+}
+
+func TestCommandExpand(t *testing.T) {
+	deep := map[string]string{}
+	for i := 0; i <= ALIAS_DEPTH; i++ {
+		deep[string(rune('a'+i))+"deep"] = string(rune('a'+i+1)) + "deep"
+	}
+	cases := []struct {
+		name    string
+		aliases map[string]string
+		line    string
+		want    []string
+		warned  bool
+	}{
+		{"plain", nil, "look", []string{"look"}, false},
+		{"blank", nil, "  ", []string{}, false},
+		{"stacked", nil, "n; ;e;look", []string{"n", "e", "look"}, false},
+		{"speedwalk", nil, "2n;look", []string{"north", "north", "look"}, false},
+		{"alias", map[string]string{"kk": "kill $1"}, "kk trooper", []string{"kill trooper"}, false},
+		{"alias case", map[string]string{"kk": "kill $1"}, "KK trooper", []string{"kill trooper"}, false},
+		{"alias stacks", map[string]string{"gs": "get all;sac corpse"}, "gs;n", []string{"get all", "sac corpse", "n"}, false},
+		{"alias speedwalks", map[string]string{"home": "3s2w"}, "home", []string{"south", "south", "south", "west", "west"}, false},
+		{"alias in alias", map[string]string{"kk": "kill $1", "kt": "kk trooper"}, "kt", []string{"kill trooper"}, false},
+		{"itself in its body", map[string]string{"look": "look auto"}, "look", []string{"look auto"}, false},
+		{"itself with args", map[string]string{"look": "look auto"}, "look north", []string{"look auto north"}, false},
+		{"each other", map[string]string{"a": "b x", "b": "a y"}, "a", []string{"a y x"}, false},
+		{`\; in the arguments`, map[string]string{"ss": "say $*"}, `ss hi\; there;bow`, []string{"say hi; there", "bow"}, false},
+		{"too deep", deep, "adeep", []string{}, true},
+		{"too many", map[string]string{"x": strings.Repeat("look;", ALIAS_EXPAND_MAX+5)}, "x", nil, true},
+		{"too far", nil, "999n", nil, true},
+	}
+	for _, c := range cases {
+		client := new_test_client(c.name, nil)
+		player := new(PlayerProfile)
+		player.Client = client
+		player.Aliases = c.aliases
+		got := command_expand(player, c.line)
+		if c.want != nil && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, wanted %q", c.name, got, c.want)
+		}
+		if len(got) > ALIAS_EXPAND_MAX {
+			t.Errorf("%s: %d commands, more than %d", c.name, len(got), ALIAS_EXPAND_MAX)
+		}
+		if c.want == nil && len(got) != ALIAS_EXPAND_MAX {
+			t.Errorf("%s: %d commands, wanted it cut at %d", c.name, len(got), ALIAS_EXPAND_MAX)
+		}
+		if warned := atomic.LoadInt64(&client.sent) > 0; warned != c.warned {
+			t.Errorf("%s: warned %v, wanted %v", c.name, warned, c.warned)
+		}
+	}
+}
@@ -19,10 +19,12 @@ package swr
 
 import (
 	"fmt"
+	"html"
 	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -162,6 +164,15 @@ Tokens for background color are:
 ^y - Yellow        ^b - Blue          ^p - Purple
 ^c - Cyan          ^w - Grey
 
+Extended colors: &[n] ^[n] }[n]
+n is an xterm 256 color number (0-255), or #rrggbb for a truecolor. These
+get knocked down to the nearest color the player's terminal can show, so
+&[#ff8700] is orange in Mudlet and plain yellow in a 16 color telnet.
+
+Clients that tell us (TTYPE/MTTS) they can't do ANSI get no color at all,
+and clients that can't do UTF-8 get box drawing turned into +-|= and the
+emoji dropped.
+
 If setting both foreground and background colors. The foreground must
 be used before the background color. Also, the last color setting in your
 prompt will wash over into the text you type. So, if you want a set
@@ -208,178 +219,293 @@ func (c *Colorize) IsRightArrow(input string) bool {
 	return input == ANSI_CURSOR_RIGHT
 }
 
+// Colorize renders color codes for a plain 16 color ANSI terminal.
 func (c *Colorize) Colorize(input string) string {
+	return c.colorize(input, TERM_CAPS_DEFAULT)
+}
+
+// Render gets a string ready for a particular terminal: colors as deep as it
+// can show (or none at all) and ASCII instead of UTF-8 if it has to.
+func (c *Colorize) Render(input string, caps TermCaps) string {
+	if caps.Ansi() {
+		input = c.colorize(input, caps)
+	} else {
+		input = c.Decolorize(input)
+	}
+	if !caps.UTF8() {
+		input = ascii_fold(input)
+	}
+	return input
+}
+
+func (c *Colorize) colorize(input string, caps TermCaps) string {
 	// &=FG code
 	// ^=BG code
 	// }=Blink codes
+	return ansi_code.ReplaceAllStringFunc(input, func(str string) string {
+		return ansi_render(str, caps)
+	})
+}
 
-	color_func := func(str string) string {
-		switch str {
-		case "&x":
-			return ANSI_ESC + "0;" + ANSI_FG_BLACK + "m"
-		case "&r":
-			return ANSI_ESC + "0;" + ANSI_FG_RED + "m"
-		case "&g":
-			return ANSI_ESC + "0;" + ANSI_FG_GREEN + "m"
-		case "&y":
-			return ANSI_ESC + "0;" + ANSI_FG_YELLOW + "m"
-		case "&b":
-			return ANSI_ESC + "0;" + ANSI_FG_BLUE + "m"
-		case "&p":
-			return ANSI_ESC + "0;" + ANSI_FG_MAGENTA + "m"
-		case "&c":
-			return ANSI_ESC + "0;" + ANSI_FG_CYAN + "m"
-		case "&w":
-			return ANSI_ESC + "0;" + ANSI_FG_WHITE + "m"
-		case "&X":
-			return ANSI_BOLD + ANSI_FG_BLACK + "m"
-		case "&R":
-			return ANSI_BOLD + ANSI_FG_RED + "m"
-		case "&G":
-			return ANSI_BOLD + ANSI_FG_GREEN + "m"
-		case "&Y":
-			return ANSI_BOLD + ANSI_FG_YELLOW + "m"
-		case "&B":
-			return ANSI_BOLD + ANSI_FG_BLUE + "m"
-		case "&P":
-			return ANSI_BOLD + ANSI_FG_MAGENTA + "m"
-		case "&C":
-			return ANSI_BOLD + ANSI_FG_CYAN + "m"
-		case "&W":
-			return ANSI_BOLD + ANSI_FG_WHITE + "m"
-		case "^x":
-			return ANSI_ESC + ANSI_BG_BLACK + "m"
-		case "^r":
-			return ANSI_ESC + ANSI_BG_RED + "m"
-		case "^g":
-			return ANSI_ESC + ANSI_BG_GREEN + "m"
-		case "^y":
-			return ANSI_ESC + ANSI_BG_YELLOW + "m"
-		case "^b":
-			return ANSI_ESC + ANSI_BG_BLUE + "m"
-		case "^p":
-			return ANSI_ESC + ANSI_BG_MAGENTA + "m"
-		case "^c":
-			return ANSI_ESC + ANSI_BG_CYAN + "m"
-		case "^w":
-			return ANSI_ESC + ANSI_BG_WHITE + "m"
-		case "^X":
-			return ANSI_BOLD + ANSI_BG_BLACK + "m"
-		case "^R":
-			return ANSI_BOLD + ANSI_BG_RED + "m"
-		case "^G":
-			return ANSI_BOLD + ANSI_BG_GREEN + "m"
-		case "^Y":
-			return ANSI_BOLD + ANSI_BG_YELLOW + "m"
-		case "^B":
-			return ANSI_BOLD + ANSI_BG_BLUE + "m"
-		case "^P":
-			return ANSI_BOLD + ANSI_BG_MAGENTA + "m"
-		case "^C":
-			return ANSI_BOLD + ANSI_BG_CYAN + "m"
-		case "^W":
-			return ANSI_BOLD + ANSI_BG_WHITE + "m"
-		case "}x":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_BLACK + "m"
-		case "}r":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_RED + "m"
-		case "}g":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_GREEN + "m"
-		case "}y":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_YELLOW + "m"
-		case "}b":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_BLUE + "m"
-		case "}p":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_MAGENTA + "m"
-		case "}c":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_CYAN + "m"
-		case "}w":
-			return ANSI_BLINK + ANSI_ESC + ANSI_FG_WHITE + "m"
-		case "}X":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_BLACK + "m"
-		case "}R":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_RED + "m"
-		case "}G":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_GREEN + "m"
-		case "}Y":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_YELLOW + "m"
-		case "}B":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_BLUE + "m"
-		case "}P":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_MAGENTA + "m"
-		case "}C":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_CYAN + "m"
-		case "}W":
-			return ANSI_BLINK + ANSI_BOLD + ANSI_FG_WHITE + "m"
-		case "&U", "&u":
-			return ANSI_UNDERLINE
-		case "&I", "&i":
-			return ANSI_ITALIC
-		case "&&":
-			return "&"
-		case "^^":
-			return "^"
-		case "}}":
-			return "}"
-		case "&d", "&D":
-			return ANSI_RESET
-		default:
-			return str
+func (c *Colorize) Decolorize(input string) string {
+	// &=FG code
+	// ^=BG code
+	// }=Blink code
+	return ansi_code.ReplaceAllStringFunc(input, func(str string) string {
+		if str[1] == str[0] {
+			return str[1:]
 		}
-	}
-
-	r, err := regexp.Compile(`&[a-zA-z&]`) // foreground
-	ErrorCheck(err)
+		if _, ok := ansi_sgr(str); ok {
+			return ""
+		}
+		return str
+	})
+}
 
-	input = r.ReplaceAllStringFunc(input, color_func)
+// Every color code, &x ^x }x plus the xterm ones, &[208] or &[#ff8700].
+var ansi_code = regexp.MustCompile(`&(?:[a-zA-Z&]|\[#?[0-9a-fA-F]+\])|\^(?:[a-zA-Z^]|\[#?[0-9a-fA-F]+\])|}(?:[a-zA-Z}]|\[#?[0-9a-fA-F]+\])`)
 
-	r, err = regexp.Compile(`\^[a-zA-z^]`) // background
-	ErrorCheck(err)
+// The color letters, uppercase is the bright half of the palette.
+var ansi_colors = map[byte]int{'x': 0, 'r': 1, 'g': 2, 'y': 3, 'b': 4, 'p': 5, 'c': 6, 'w': 7}
 
-	input = r.ReplaceAllStringFunc(input, color_func)
-
-	r, err = regexp.Compile(`}[a-zA-z}]`) // foreground
-	ErrorCheck(err)
+// What xterm shows for the first 16, used for finding the closest match.
+var ansi_palette = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
 
-	input = r.ReplaceAllStringFunc(input, color_func)
-	return input
+type ansi_color struct {
+	tag   byte   // & ^ or }
+	index int    // 0-15 for the letters, 0-255 for &[n]
+	rgb   []int  // only for &[#rrggbb]
+	attr  string // reset, underline and italic aren't colors at all
 }
-func (c *Colorize) Decolorize(input string) string {
-	// &=FG code
-	// ^=BG code
-	// }=Blink code
-	// ]=Underline code
 
-	color_func := func(str string) string {
-		switch str {
-		case "&d", "&D", "&x", "&r", "&g", "&y", "&b", "&p", "&c", "&w", "&X", "&R", "&G", "&Y", "&B", "&P", "&C", "&W", "&U", "&u", "&I", "&i", "^x", "^r", "^g", "^y", "^b", "^p", "^c", "^w", "^X", "^R", "^G", "^Y", "^B", "^P", "^C", "^W", "}x", "}r", "}g", "}y", "}b", "}p", "}c", "}w", "}X", "}R", "}G", "}Y", "}B", "}P", "}C", "}W":
-			return ""
-		case "&&":
-			return "&"
-		case "^^":
-			return "^"
-		case "}}":
-			return "}"
-		default:
-			return str
+func ansi_sgr(str string) (ansi_color, bool) {
+	col := ansi_color{tag: str[0]}
+	code := str[1:]
+	if code[0] == '[' {
+		code = code[1 : len(code)-1]
+		if strings.HasPrefix(code, "#") {
+			if len(code) != 7 {
+				return col, false
+			}
+			v, err := strconv.ParseUint(code[1:], 16, 32)
+			if err != nil {
+				return col, false
+			}
+			col.rgb = []int{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}
+			return col, true
 		}
+		n, err := strconv.Atoi(code)
+		if err != nil || n > 255 {
+			return col, false
+		}
+		col.index = n
+		return col, true
+	}
+	if col.tag == '&' {
+		switch code {
+		case "d", "D":
+			col.attr = ANSI_RESET
+			return col, true
+		case "u", "U":
+			col.attr = ANSI_UNDERLINE
+			return col, true
+		case "i", "I":
+			col.attr = ANSI_ITALIC
+			return col, true
+		}
+	}
+	n, ok := ansi_colors[code[0]|0x20]
+	if !ok {
+		return col, false
 	}
+	if code[0] < 'a' {
+		n += 8
+	}
+	col.index = n
+	return col, true
+}
 
-	r, err := regexp.Compile(`&[a-zA-z&]`) // foreground
-	ErrorCheck(err)
+// Turn one color code into an escape sequence the terminal understands,
+// knocking xterm colors down to 256 or 16 if that's all it has.
+func ansi_render(str string, caps TermCaps) string {
+	if str[1] == str[0] {
+		return str[1:]
+	}
+	col, ok := ansi_sgr(str)
+	if !ok {
+		return str
+	}
+	if col.attr != "" {
+		return col.attr
+	}
+	base := 38
+	if col.tag == '^' {
+		base = 48
+	}
+	switch {
+	case col.rgb != nil && caps.TrueColor():
+		return ansi_seq(col.tag, false, sprintf("%d;2;%d;%d;%d", base, col.rgb[0], col.rgb[1], col.rgb[2]))
+	case col.rgb != nil && caps.Color256():
+		return ansi_seq(col.tag, false, sprintf("%d;5;%d", base, xterm_index(col.rgb)))
+	case col.index > 15 && caps.Color256():
+		return ansi_seq(col.tag, false, sprintf("%d;5;%d", base, col.index))
+	}
+	n := col.index
+	if col.rgb != nil {
+		n = ansi_nearest(col.rgb)
+	} else if n > 15 {
+		n = ansi_nearest(xterm_rgb(n))
+	}
+	return ansi_seq(col.tag, n > 7, strconv.Itoa(base-8+n%8))
+}
 
-	input = r.ReplaceAllStringFunc(input, color_func)
+// The 16 colors come out exactly how they always have, &x resets bold and
+// the bright ones are bold.
+func ansi_seq(tag byte, bright bool, params string) string {
+	prefix := ""
+	if tag == '&' {
+		prefix = "0;"
+	}
+	if bright {
+		prefix = "1;"
+	}
+	seq := ANSI_ESC + prefix + params + "m"
+	if tag == '}' {
+		seq = ANSI_BLINK + seq
+	}
+	return seq
+}
 
-	r, err = regexp.Compile(`\^[a-zA-z^]`) // background
-	ErrorCheck(err)
+func xterm_rgb(n int) []int {
+	if n < 16 {
+		return ansi_palette[n][:]
+	}
+	if n >= 232 {
+		v := 8 + (n-232)*10
+		return []int{v, v, v}
+	}
+	n -= 16
+	level := func(v int) int {
+		if v == 0 {
+			return 0
+		}
+		return 55 + v*40
+	}
+	return []int{level(n / 36), level(n / 6 % 6), level(n % 6)}
+}
 
-	input = r.ReplaceAllStringFunc(input, color_func)
+// Closest color in the 6x6x6 cube or the grey ramp.
+func xterm_index(rgb []int) int {
+	cube := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+	n := 16 + cube(rgb[0])*36 + cube(rgb[1])*6 + cube(rgb[2])
+	grey := (rgb[0] + rgb[1] + rgb[2]) / 3
+	g := 232
+	if grey > 238 {
+		g = 255
+	} else if grey > 8 {
+		g = 232 + (grey-3)/10
+	}
+	if color_distance(xterm_rgb(g), rgb) < color_distance(xterm_rgb(n), rgb) {
+		return g
+	}
+	return n
+}
 
-	r, err = regexp.Compile(`}[a-zA-z}]`) // blinky
-	ErrorCheck(err)
+func ansi_nearest(rgb []int) int {
+	best := 0
+	for i := range ansi_palette {
+		if color_distance(ansi_palette[i][:], rgb) < color_distance(ansi_palette[best][:], rgb) {
+			best = i
+		}
+	}
+	return best
+}
 
-	input = r.ReplaceAllStringFunc(input, color_func)
-	return input
+func color_distance(a []int, b []int) int {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+var ansi_csi = regexp.MustCompile("\x1b\\[([0-9;?]*)([A-Za-z])")
+
+// Htmlize turns colorized (ANSI) text into escaped html with <span> classes for
+// the web client. Anything that isn't a color (cursor movement, clear) is dropped.
+func (c *Colorize) Htmlize(input string) string {
+	var out strings.Builder
+	fg, bg := 0, 0
+	bold, italic, underline, blink := false, false, false, false
+	open := false
+	last := 0
+	for _, m := range ansi_csi.FindAllStringSubmatchIndex(input, -1) {
+		out.WriteString(html.EscapeString(input[last:m[0]]))
+		last = m[1]
+		if input[m[4]:m[5]] != "m" {
+			continue
+		}
+		for _, code := range strings.Split(input[m[2]:m[3]], ";") {
+			n, _ := strconv.Atoi(code)
+			switch {
+			case n == 0:
+				fg, bg = 0, 0
+				bold, italic, underline, blink = false, false, false, false
+			case n == 1:
+				bold = true
+			case n == 3:
+				italic = true
+			case n == 4:
+				underline = true
+			case n == 5:
+				blink = true
+			case n >= 30 && n <= 37:
+				fg = n
+			case n >= 40 && n <= 47:
+				bg = n
+			}
+		}
+		if open {
+			out.WriteString("</span>")
+			open = false
+		}
+		classes := make([]string, 0)
+		if fg > 0 {
+			classes = append(classes, sprintf("fg%d", fg))
+		}
+		if bg > 0 {
+			classes = append(classes, sprintf("bg%d", bg))
+		}
+		if bold {
+			classes = append(classes, "bold")
+		}
+		if italic {
+			classes = append(classes, "italic")
+		}
+		if underline {
+			classes = append(classes, "underline")
+		}
+		if blink {
+			classes = append(classes, "blink")
+		}
+		if len(classes) > 0 {
+			out.WriteString(sprintf("<span class=\"%s\">", strings.Join(classes, " ")))
+			open = true
+		}
+	}
+	out.WriteString(html.EscapeString(input[last:]))
+	if open {
+		out.WriteString("</span>")
+	}
+	return strings.ReplaceAll(out.String(), "\r\n", "\n")
 }
 
 const (
@@ -399,47 +525,97 @@ const (
 	ANSI_TITLE_STYLE_SENATE
 )
 
+const (
+	TERM_WIDTH_DEFAULT = 80
+	TERM_WIDTH_MIN     = 40
+	TERM_WIDTH_MAX     = 250
+)
+
+// Clamp whatever the client told us (NAWS, pty) to something we can draw in.
+func term_width(width int) int {
+	if width <= 0 {
+		return TERM_WIDTH_DEFAULT
+	}
+	if width < TERM_WIDTH_MIN {
+		return TERM_WIDTH_MIN
+	}
+	if width > TERM_WIDTH_MAX {
+		return TERM_WIDTH_MAX
+	}
+	return width
+}
+
+// How many columns a string takes up on screen, color codes and escapes don't count.
+func visible_len(str string) int {
+	return utf8.RuneCountInString(ansi_csi.ReplaceAllString(Color().Decolorize(str), ""))
+}
+
+// Like %-*s but counts what's visible instead of bytes.
+func pad_right(str string, width int) string {
+	n := visible_len(str)
+	if n >= width {
+		return str
+	}
+	return str + strings.Repeat(" ", width-n)
+}
+
+// Repeat a pattern until it's exactly n characters long.
+func title_fill(pattern string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat(pattern, n/len(pattern)+1)[:n]
+}
+
 // Takes a string and makes a Title based on style [ANSI_TITLE_STYLE_] and alignment [ANSI_TITLE_ALIGNMENT_]
-func MakeTitle(title string, style int, alignment int) string {
+// stretched to fit a terminal width columns wide.
+func MakeTitle(title string, style int, alignment int, width int) string {
+	width = term_width(width)
+	// t is the line less the caps and the spaces around the title
 	t := ""
 	cap_left := ""
 	cap_right := ""
 	switch style {
 	case ANSI_TITLE_STYLE_NORMAL:
-		t = strings.Repeat("-=", 38) + "-"
+		t = title_fill("-=", width-4)
 		cap_left = "("
 		cap_right = ")"
 	case ANSI_TITLE_STYLE_BLOCK:
-		t = strings.Repeat("==", 38) + "="
+		t = title_fill("==", width-4)
 		cap_left = "["
 		cap_right = "]"
 	case ANSI_TITLE_STYLE_ELEGANT:
-		t = strings.Repeat("-~", 38) + "-"
+		t = title_fill("-~", width-4)
 		cap_left = "{"
 		cap_right = "}}"
 	case ANSI_TITLE_STYLE_HACKED:
-		t = strings.Repeat("-/\\#", 19) + "-"
+		t = title_fill("-/\\#", width-4)
 		cap_left = "<"
 		cap_right = ">"
 	case ANSI_TITLE_STYLE_IMPERIAL:
-		t = strings.Repeat("::", 39)
+		t = title_fill("::", width-4)
 		cap_left = ":"
 		cap_right = ":"
 	case ANSI_TITLE_STYLE_REBEL:
-		t = strings.Repeat("::", 39)
+		t = title_fill("::", width-4)
 		cap_left = ":"
 		cap_right = ":"
 	case ANSI_TITLE_STYLE_SENATE:
-		t = strings.Repeat("-=", 38) + "-"
+		t = title_fill("-=", width-4)
 		cap_left = "["
 		cap_right = "]"
 	default:
-		t = "+----------------------------------------------------------------------------+"
+		t = "+" + strings.Repeat("-", width-6) + "+"
 		cap_left = "["
 		cap_right = "]"
 	}
+	title_length := visible_len(title)
+	if title_length > len(t)-6 {
+		// by what shows, cutting bytes could leave half a rune or color code
+		title = string([]rune(Color().Decolorize(title))[:len(t)-6])
+		title_length = len(t) - 6
+	}
 	offset := 0
-	title_length := len(title)
 
 	switch alignment {
 	case ANSI_TITLE_ALIGNMENT_CENTER:
@@ -529,7 +705,9 @@ func MakeTunerBar(freq string, size int) string {
 	return ret
 }
 
-func StitchParagraphs(paragraph1 string, paragraph2 string) string {
+// Puts paragraph2 (usually the map) to the right of paragraph1, in a terminal
+// width columns wide.
+func StitchParagraphs(paragraph1 string, paragraph2 string, width int) string {
 	p1_parts := strings.Split(paragraph1, "\r\n")
 
 	p2_parts := strings.Split(paragraph2, "\r\n")
@@ -540,6 +718,7 @@ func StitchParagraphs(paragraph1 string, paragraph2 string) string {
 	if p2_len > p1_len {
 		tallest = p2_len
 	}
+	a_width := term_width(width) - 11 // and a space, and the map's 10
 
 	buf := ""
 	for row := 0; row < tallest; row++ {
@@ -551,11 +730,69 @@ func StitchParagraphs(paragraph1 string, paragraph2 string) string {
 		if row < p2_len {
 			b_side = p2_parts[row]
 		}
-		buf += sprintf("%-*s %-*s\r\n", 70, a_side, 10, b_side)
+		buf += sprintf("%s %s\r\n", pad_right(a_side, a_width), pad_right(b_side, 10))
 	}
 	return buf
 }
 
+// WordWrap reflows text to width columns. Lines that start with whitespace are
+// treated as preformatted (lists, signs on the wall) and kept as they are, and
+// blank lines split paragraphs.
+func WordWrap(str string, width int) string {
+	lines := strings.Split(strings.ReplaceAll(str, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	paragraph := make([]string, 0)
+	flush := func() {
+		line := ""
+		for _, word := range paragraph {
+			if line != "" && visible_len(line)+1+visible_len(word) > width {
+				out = append(out, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		if line != "" {
+			out = append(out, line)
+		}
+		paragraph = paragraph[:0]
+	}
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			flush()
+			out = append(out, "")
+			continue
+		}
+		if l[0] == ' ' || l[0] == '\t' {
+			flush()
+			out = append(out, strings.TrimRight(l, " \t"))
+			continue
+		}
+		paragraph = append(paragraph, strings.Fields(l)...)
+	}
+	flush()
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, "\r\n")
+}
+
+// A horizontal line for box tables, ex: ├─( Stats )──────┤ with inner being
+// the width between the two corners.
+func box_rule(left string, fill string, right string, lead int, label string, inner int) string {
+	buf := strings.Repeat(fill, lead)
+	if label != "" {
+		buf += "( " + label + " )"
+	}
+	n := inner - visible_len(buf)
+	if n > 0 {
+		buf += strings.Repeat(fill, n)
+	}
+	return left + buf + right
+}
+
 func tstring(str string, length int) string {
 	if len(str) < length {
 		return str
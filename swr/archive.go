@@ -61,7 +61,9 @@ func StartBackup() {
 func DoBackup(t time.Time) {
 	log.Printf("***** BACKUP STARTED *****\r\n")
 
-	DB().Save()
+	game_sync(func() {
+		DB().Save()
+	})
 	create_backup(t)
 	runtime.GC()
 	log.Printf("***** BACKUP COMPLETE *****\r\n")
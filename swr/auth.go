@@ -18,7 +18,6 @@
 package swr
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"log"
 	"os"
@@ -37,18 +36,24 @@ type Account struct {
 	Email      string
 	Priv       uint
 	Characters []PlayerRef
+	// forgot password, a sha256 of the token we mailed out
+	ResetToken   string
+	ResetExpires time.Time
 }
 
 type PlayerRef struct {
 	gorm.Model
 	AccountID uint
+	Name      string `gorm:"index:idx_player_name,unique"`
 	Data      string
 }
 
 func auth_do_welcome(client Client) {
 	client.Send(Color().ClearScreen())
 	client.Send("\r\n\r\n&CA long time ago in a galaxy far, far away...&d\r\n\r\n\r\n[press ENTER]")
-	_ = client.Read()
+	if mssp_request(client, client.Read()) {
+		return
+	}
 	welcome, err := os.ReadFile("data/sys/welcome")
 	ErrorCheck(err)
 	client.Send(telnet_encode(string(welcome)))
@@ -56,98 +61,81 @@ func auth_do_welcome(client Client) {
 }
 
 func auth_do_login(client Client) {
+	tries := 0
 Login:
 	client.Send("\r\n&GHolonet Login:&d ")
 	username := client.Read()
+	if mssp_request(client, username) || client.IsClosed() {
+		return
+	}
 	if username == "" {
 		goto Login
 	}
 	sanitized := strings.TrimSpace(strings.ToLower(username))
-	path := fmt.Sprintf("data/accounts/%s/%s.yml", sanitized[0:1], sanitized)
-	log.Printf("Loading player %s", sanitized)
-	if file_exists(path) {
-		player := DB().ReadPlayerData(path)
-		client.Send("\r\n&GPassword:&d ")
+	log.Printf("Loading account %s", sanitized)
+	account := account_find(sanitized)
+	if account != nil {
+		if wait, locked := Bans().LoginWait(client.GetAddr(), account.Username); locked {
+			client.Sendf("\r\n}RToo many failed logins, try again in %s.&d\r\n", wait.Round(time.Second))
+			client.Close()
+			return
+		} else if wait > 0 {
+			time.Sleep(wait)
+		}
+		client.Send("\r\n&GPassword &x(or &Wforgot&x)&G:&d ")
 		telnet_disable_local_echo(client)
 		password := client.Read()
 		telnet_enable_local_echo(client)
-		if encrypt_string(password) != player.Password {
-			fmt.Printf("%s\n", password)
-			client.Send("\r\n}RInvalid password!&d\r\n")
-			goto Login
+		if client.IsClosed() {
+			return
 		}
-		if encrypt_string(password) == player.Password {
-			client.Send(fmt.Sprintf("\r\n&GAccess granted! Welcome %s.&d\r\n", player.Char.Name))
-			time.Sleep(1 * time.Second)
-			client.Send(Color().ClearScreen())
-			player.LastSeen = time.Now()
-			player.Client = client
-			DB().SavePlayerData(player)
-			room := DB().GetRoom(player.Char.Room, player.Char.Ship)
-			room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has arrived.\r\n", player.Char.Name))
-			// see if player is already in the game...
-			p := DB().GetPlayerEntityByName(player.Char.Name)
-			if p == nil {
-				DB().AddEntity(player)
-			} else {
-				client.Send("\r\nReconnecting to player...\r\n")
-				player = p.(*PlayerProfile)
-				if player.Client != nil {
-					// disconnect old client.
-					player.Client.Send("\r\n&RAnother player has logged in as this character!!!\r\n")
-					player.Client.Close()
-					DB().RemoveClient(player.Client)
-					time.Sleep(1 * time.Second) // sleep a little to make sure they're kicked...
-				}
-				player.Client = client
-				DB().AddEntity(player)
-				player.LastSeen = time.Now()
-			}
-			ServerQueue <- MudClientCommand{
-				Entity:  player,
-				Command: "look",
+		switch {
+		case account.CheckPassword(password):
+		case strings.EqualFold(password, "forgot"):
+			auth_do_forgot(client, account)
+			goto Login
+		case account.CheckResetToken(password):
+			Log(LOG_AUTH).Wiz(WIZ_LOGINS).Info("login with a reset token", "account", account.Username, "addr", client.GetAddr())
+			client.Send("\r\n&GToken accepted. Time for a new password.&d\r\n")
+			password = auth_do_choose_password(client)
+			if password == "" {
+				return
 			}
-			for _, e := range room.GetEntities() {
-				if e.GetCharData().AI != nil {
-					e.GetCharData().AI.OnGreet(player)
-				}
+			account.SetPassword(password)
+		default:
+			Log(LOG_AUTH).Wiz(WIZ_LOGINS).Warn("failed login", "account", account.Username, "addr", client.GetAddr())
+			Bans().LoginFailed(client.GetAddr(), account.Username)
+			client.Send("\r\n}RInvalid password!&d\r\n")
+			tries++
+			if tries >= LOGIN_TRIES {
+				client.Send("\r\n}RToo many tries, goodbye.&d\r\n")
+				client.Close()
+				return
 			}
+			goto Login
 		}
+		Bans().LoginOk(client.GetAddr(), account.Username)
+		auth_do_menu(client, account)
 	} else {
 		client.Send("\r\n&rHrm, it seems there isn't a record of you in the galactic databank.\r\n\r\n&rAre you &Wnew&r? &G[&Wy&G/&Wn&G]&d ")
 		are_new := strings.ToLower(client.Read())
 		if strings.HasPrefix(are_new, "y") {
-			player := new(PlayerProfile)
-			player.Char = CharData{}
-			player.Char.Name = username
-			auth_do_new_player(client, player)
+			auth_do_new_account(client, sanitized)
 		} else {
 			goto Login
 		}
 	}
 }
 
-func auth_do_new_player(client Client, player *PlayerProfile) {
-	// ch is a new Character. Allocated but unassigned in the game world.
-	// complete initialization, associate, and load into the game as that
-	// character.
-Name:
-	client.Send("\r\n&GCharacter Name:&d ")
-	name := client.Read()
-	if strings.ContainsAny(name, "`~,./?<>;:'\"[]}{\\|+_-=!@#$%^&*()") {
-		client.Send("}RSpecial characters are not allowed.&d\r\n\r\n")
-		goto Name
-	}
-	client.Sendf("\r\n&GYou will be known as &W%s&G. Is that ok? [&Wy&G/&Wn&G] &d", name)
-	name_confirm := client.Read()
-	if !strings.HasPrefix(strings.ToLower(name_confirm), "y") {
-		goto Name
-	}
-	client.Sendf("\r\n&GWelcome &W%s&G.\r\n", name)
+// Asks for a new password, twice. Empty if they hung up on us.
+func auth_do_choose_password(client Client) string {
 Password:
-	client.Sendf("&GPlease enter a &Wpassword&G:&d ")
+	client.Sendf("\r\n&GPlease enter a &Wpassword&G:&d ")
 	telnet_disable_local_echo(client)
 	password := client.Read()
+	if client.IsClosed() {
+		return ""
+	}
 	if strings.ContainsAny(password, " \x00\t") {
 		client.Sendf("\r\n&RInvalid password, passwords cannot contain spaces or control chars.&d\r\n")
 		goto Password
@@ -159,132 +147,305 @@ Password:
 		client.Send("\r\n}RError! Password mismatch!&d\r\n")
 		goto Password
 	}
+	return password
+}
+
+func auth_do_forgot(client Client, account *Account) {
+	if !strings.Contains(account.Email, "@") {
+		client.Send("\r\n}RThere is no email on file for this account, ask an immortal for help.&d\r\n")
+		return
+	}
+	if err := account.SendResetToken(); err != nil {
+		Log(LOG_AUTH).Error("error sending a reset token", "account", account.Username, "err", err)
+		client.Send("\r\n}RUnable to send a reset token right now, try again in a few minutes.&d\r\n")
+		return
+	}
+	client.Sendf("\r\n&GA reset token is on its way to &W%s&G. Log in with it in place of your password within &W%d&G minutes.&d\r\n",
+		mask_email(account.Email), int(RESET_TOKEN_TTL.Minutes()))
+}
+
+func auth_do_new_account(client Client, username string) {
+	if strings.ContainsAny(username, "`~,./?<>;:'\"[]}{\\|+_-=!@#$%^&*() \t") || len(username) < 3 {
+		client.Send("\r\n}RAccount names need at least 3 letters and no special characters.&d\r\n")
+		auth_do_login(client)
+		return
+	}
+	if !account_name_free(username, nil) {
+		client.Send("\r\n}RThat name is already taken.&d\r\n")
+		auth_do_login(client)
+		return
+	}
+	client.Sendf("\r\n&GYour account will be called &W%s&G. Is that ok? [&Wy&G/&Wn&G] &d", username)
+	if !strings.HasPrefix(strings.ToLower(client.Read()), "y") {
+		auth_do_login(client)
+		return
+	}
+	password := auth_do_choose_password(client)
+	if password == "" {
+		return
+	}
 Email:
 	client.Send("\r\n&GPlease enter your email &x(we won't spam you)&G:&d ")
 	email := client.Read()
+	if client.IsClosed() {
+		return
+	}
 	if !strings.Contains(email, "@") {
 		client.Send("\r\n}RError, an email address is needed for account recovery purposes.&d\r\n")
 		goto Email
 	}
+	account := account_create(username, password, email)
+	if account == nil {
+		client.Send("\r\n}RThe galactic databank is having trouble, try again later.&d\r\n")
+		client.Close()
+		return
+	}
+	Log(LOG_AUTH).Wiz(WIZ_LOGINS).Info("new account", "account", account.Username, "addr", client.GetAddr())
+	auth_do_new_player(client, account)
+}
+
+// The character menu, pick one of the account's characters or make a new one.
+func auth_do_menu(client Client, account *Account) {
+Menu:
+	client.Sendf("\r\n%s\r\n\r\n", MakeTitle(sprintf("Account: %s", account.Username), ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER, client.GetWidth()))
+	for i, ref := range account.Characters {
+		client.Sendf("&Y[&w%2d&Y] &W%s\r\n", i+1, capitalize(ref.Name))
+	}
+	client.Send("&Y[&w N&Y] &WCreate a new character\r\n")
+	client.Send("&Y[&w Q&Y] &WQuit\r\n")
+	client.Send("\r\n&GSelection:&d ")
+	choice := strings.ToLower(client.Read())
+	if client.IsClosed() {
+		return
+	}
+	switch choice {
+	case "":
+		goto Menu
+	case "n", "new":
+		auth_do_new_player(client, account)
+		return
+	case "q", "quit":
+		client.Send("\r\nGoodbye.\r\n\r\n&xThe terminal view fades away and all you see is black.&d\r\n")
+		client.Close()
+		return
+	}
+	for i, ref := range account.Characters {
+		if choice == strconv.Itoa(i+1) || choice == ref.Name {
+			player := account.LoadCharacter(ref)
+			if player == nil {
+				client.Send("\r\n}RThat character seems to be lost in hyperspace.&d\r\n")
+				goto Menu
+			}
+			if ban_check_player(client, player) {
+				goto Menu
+			}
+			auth_do_enter(client, player)
+			return
+		}
+	}
+	client.Send("\r\n}RNo such character, try again.&d\r\n")
+	goto Menu
+}
+
+// Puts an authenticated player into the world, or back into their body if
+// they never left.
+func auth_do_enter(client Client, player *PlayerProfile) {
+	client.Send(fmt.Sprintf("\r\n&GAccess granted! Welcome %s.&d\r\n", player.Char.Name))
+	time.Sleep(1 * time.Second)
+	client.Send(Color().ClearScreen())
+	player.LastSeen = time.Now()
+	player.Client = client
+	game_sync(func() {
+		room := DB().GetRoom(player.Char.Room, player.Char.Ship)
+		// see if player is already in the game...
+		p := DB().GetPlayerEntityByName(player.Char.Name)
+		if p == nil {
+			if room == nil {
+				room = player_relocate(player)
+			}
+			if room == nil {
+				client.Send("\r\n}RThere's nowhere in the galaxy to put you, the immortals have been told.&d\r\n")
+				client.Close()
+				return
+			}
+			// only the fresh copy, the one in the game is newer than the file
+			DB().SavePlayerData(player)
+			room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has arrived.\r\n", player.Char.Name))
+			DB().AddEntity(player)
+		} else if p.(*PlayerProfile).IsLinkdead() {
+			client.Send("\r\nReconnecting to player...\r\n")
+			player = p.(*PlayerProfile)
+			room = DB().GetRoom(player.Char.Room, player.Char.Ship)
+			player_reconnect(player, client)
+		} else {
+			client.Send("\r\nReconnecting to player...\r\n")
+			player = p.(*PlayerProfile)
+			room = DB().GetRoom(player.Char.Room, player.Char.Ship)
+			if room != nil {
+				room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has arrived.\r\n", player.Char.Name))
+			}
+			if player.Client != nil {
+				// disconnect old client.
+				player.Client.Send("\r\n&RAnother player has logged in as this character!!!\r\n")
+				player.Client.Close()
+				DB().RemoveClient(player.Client)
+			}
+			player.Client = client
+			DB().AddEntity(player)
+			player.LastSeen = time.Now()
+		}
+		Log(LOG_AUTH).Wiz(WIZ_LOGINS).Info(sprintf("%s has entered the game", player.Char.Name), "addr", client.GetAddr())
+		queue_command(player, "look")
+		if room == nil {
+			return
+		}
+		for _, e := range room.GetEntities() {
+			if e.GetCharData().AI != nil {
+				e.GetCharData().AI.OnGreet(player)
+			}
+		}
+	})
+}
+
+// The room they were saved in is gone (quarantined, or deleted since), off to
+// where their race starts out. nil if that's gone too.
+func player_relocate(player *PlayerProfile) *RoomData {
+	ch := &player.Char
+	to := uint(100)
+	if race := race_get(ch.Race); race != nil && race.Room != 0 {
+		to = race.Room
+	}
+	room := DB().GetRoom(to, 0)
+	if room == nil {
+		Log(LOG_DB).Error("player's room is gone and so is the start room", "player", ch.Name, "room", ch.Room, "start", to)
+		return nil
+	}
+	Log(LOG_DB).Warn("player's room is gone, moved to the start room", "player", ch.Name, "room", ch.Room, "ship", ch.Ship, "start", to)
+	ch.Room = to
+	ch.Ship = 0
+	return room
+}
+
+func auth_do_new_player(client Client, account *Account) {
+	// ch is a new Character. Allocated but unassigned in the game world.
+	// complete initialization, associate, and load into the game as that
+	// character.
+	player := new(PlayerProfile)
+Name:
+	client.Send("\r\n&GCharacter Name:&d ")
+	name := client.Read()
+	if client.IsClosed() {
+		return
+	}
+	if strings.ContainsAny(name, "`~,./?<>;:'\"[]}{\\|+_-=!@#$%^&*() \t") || name == "" {
+		client.Send("}RSpecial characters are not allowed.&d\r\n\r\n")
+		goto Name
+	}
+	if !account_name_free(name, account) {
+		client.Send("}RThat name is already taken.&d\r\n\r\n")
+		goto Name
+	}
+	client.Sendf("\r\n&GYou will be known as &W%s&G. Is that ok? [&Wy&G/&Wn&G] &d", name)
+	name_confirm := client.Read()
+	if !strings.HasPrefix(strings.ToLower(name_confirm), "y") {
+		goto Name
+	}
+	client.Sendf("\r\n&GWelcome &W%s&G.\r\n", name)
 
 Race:
-	client.Sendf("\r\n%s\r\n\r\n", MakeTitle("Choose Your Race", ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER))
+	client.Sendf("\r\n%s\r\n\r\n", MakeTitle("Choose Your Race", ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER, client.GetWidth()))
 
+	races := race_playable()
 	buf := ""
-	for i, race := range race_list {
-		buf += fmt.Sprintf("&Y[&w%2d&Y] &W%-12s\t", i+1, race)
+	for i, r := range races {
+		buf += fmt.Sprintf("&Y[&w%2d&Y] &W%-12s\t", i+1, r.Name)
 		if (i+1)%3 == 0 {
 			buf += "\r\n"
 		}
-		if i >= 31 {
-			break
-		}
 	}
 	client.Send(buf)
-	client.Sendf("\r\n&GRace Selection [1-%d]:&d ", 32)
-	race := client.Read()
-	r_index, err := strconv.Atoi(race)
+	client.Sendf("\r\n&GRace Selection [1-%d]:&d ", len(races))
+	r_index, err := strconv.Atoi(client.Read())
+	if client.IsClosed() {
+		return
+	}
 	if err != nil {
 		client.Send("}RUnable to parse race, please use a number!&d")
-		ErrorCheck(err)
 		goto Race
 	}
-	if r_index > 32 {
+	if r_index < 1 || r_index > len(races) {
 		client.Send("}RNumber outside of bounds. Try again.&d")
 		goto Race
 	}
-	race = race_list[r_index-1]
+	race := races[r_index-1]
 Gender:
-	client.Sendf("\r\n\r\n%s", MakeTitle("Choose Your Gender", ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER))
+	client.Sendf("\r\n\r\n%s", MakeTitle("Choose Your Gender", ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER, client.GetWidth()))
 	client.Send("\r\n&GYour character needs a gender. You can be &Wmale&G, &Wfemale&G, or &Wnon-binary&G/&Wneutral&G.\r\n")
 	client.Send("&W[&GM&W/&GF&W/&GN&W]:&d ")
 	gender := strings.ToLower(client.Read())
-	if gender[0:1] != "m" && gender[0:1] != "f" && gender[0:1] != "n" {
+	if client.IsClosed() {
+		return
+	}
+	if gender == "" || gender[0:1] != "m" && gender[0:1] != "f" && gender[0:1] != "n" {
 		client.Send("\r\n}RGender not recognized, please try again.&d\r\n")
 		goto Gender
 	}
 	gender = get_gender_for_code(strings.ToLower(gender[0:1]))
 
-	client.Sendf("\r\n\r\n%s", MakeTitle("Stats", ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER))
-	stats := make([]int, 6)
-Stats:
-	stats[0] = rand_min_max(1, 6) + rand_min_max(1, 6) + rand_min_max(1, 6)
-	stats[1] = rand_min_max(3, 6) + rand_min_max(3, 6) + rand_min_max(3, 6)
-	stats[2] = rand_min_max(3, 6) + rand_min_max(3, 6) + rand_min_max(3, 6)
-	stats[3] = rand_min_max(3, 6) + rand_min_max(3, 6) + rand_min_max(3, 6)
-	stats[4] = rand_min_max(3, 6) + rand_min_max(3, 6) + rand_min_max(3, 6)
-	stats[5] = rand_min_max(3, 6) + rand_min_max(3, 6) + rand_min_max(3, 6)
-	client.Send(fmt.Sprintf("\r\n\r\n&YSTR: &w%d  &YINT: &w%d  &YDEX: &w%d  &YWIS: &w%d  &YCON: &w%d  &YCHA: &w%d\r\n\r\n", stats[0], stats[1], stats[2], stats[3], stats[4], stats[5]))
-	client.Send("&GAre these ok? &G[&Wy&G/&Wn&G]&d ")
-	if !strings.HasPrefix(strings.ToLower(client.Read()), "y") {
-		goto Stats
+	client.Sendf("\r\n\r\n%s", MakeTitle("Stats", ANSI_TITLE_STYLE_BLOCK, ANSI_TITLE_ALIGNMENT_CENTER, client.GetWidth()))
+	stats := auth_do_stats(client, race)
+	if stats == nil {
+		return
 	}
 	player.Char = CharData{}
 	player.Char.Id = gen_player_char_id()
 	player.Char.Name = capitalize(name)
-	player.Char.Room = 100
-	player.Char.Race = race
 	player.Char.Gender = capitalize(gender)
-	player.Char.Title = fmt.Sprintf("%s the %s", player.Char.Name, player.Char.Race)
+	player.Char.Title = fmt.Sprintf("%s the %s", player.Char.Name, race.Name)
 	player.Char.Level = 1
 	player.Char.XP = 0
 	player.Char.Gold = 0
 	player.Char.Stats = stats
-	player.Char.Skills = map[string]int{}
 	player.Char.Hp = []int{50, 50}
 	player.Char.Mp = []int{0, 0}
 	player.Char.Mv = []int{50, 50}
 	player.Char.Equipment = make(map[string]*ItemData)
 	player.Char.Inventory = make([]*ItemData, 0)
-	player.Char.Keywords = []string{name, race}
+	player.Char.Keywords = []string{name, race.Name}
 	player.Char.Bank = 0
 	player.Char.Brain = "client"
-	if race == "Human" {
-		player.Char.Speaking = "basic"
-	} else if strings.Contains(race, "Droid") {
-		player.Char.Speaking = "binary"
-	} else {
-		player.Char.Speaking = strings.ToLower(race)
-	}
-	player.Char.Languages = make(map[string]int)
-	player.Char.Languages["basic"] = 100
-	player.Char.Languages[player.Char.Speaking] = 100
+	race.Init(&player.Char)
 
 	player.LastSeen = time.Now()
-	player.Password = encrypt_string(password)
-	player.Email = email
 	player.Banned = false
 	player.Frequency = tune_random_frequency()
-	player.Priv = 1
+	account.Apply(player)
 
-	client.Sendf("\r\n\r\n&GYou are about to create the character &W%s the %s&G.\r\nAre you ok with this? [&Wy&G/&Wn&G]&d ", name, race)
+	client.Sendf("\r\n\r\n&GYou are about to create the character &W%s the %s&G.\r\nAre you ok with this? [&Wy&G/&Wn&G]&d ", name, race.Name)
 	k := client.Read()
-	if strings.ToLower(k[0:1]) != "y" {
+	if client.IsClosed() {
+		return
+	}
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(k)), "y") {
 		client.Send("\r\nGoodbye.\r\n\r\n&xThe terminal view fades away and all you see is black.&d\r\n")
 		client.Close()
 		return
 	}
 	DB().SavePlayerData(player)
+	account.AddCharacter(player)
 	player.Client = client
-	room := DB().GetRoom(player.Char.Room, player.Char.Ship)
-	room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has arrived.\r\n", player.Char.Name))
-	DB().AddEntity(player)
 	client.Send(Color().ClearScreen())
 	client.Send("\r\nEntering game world...\r\n")
-	ServerQueue <- MudClientCommand{
-		Entity:  player,
-		Command: "look",
-	}
-	for _, e := range DB().GetEntitiesInRoom(player.Char.Room, player.Char.Ship) {
-		if e.GetCharData().AI != nil {
-			e.GetCharData().AI.OnGreet(player)
+	game_sync(func() {
+		room := DB().GetRoom(player.Char.Room, player.Char.Ship)
+		room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has arrived.\r\n", player.Char.Name))
+		DB().AddEntity(player)
+		queue_command(player, "look")
+		for _, e := range DB().GetEntitiesInRoom(player.Char.Room, player.Char.Ship) {
+			if e.GetCharData().AI != nil {
+				e.GetCharData().AI.OnGreet(player)
+			}
 		}
-	}
-
-}
+	})
 
-func encrypt_string(str string) string {
-	h := sha256.New()
-	h.Write([]byte(str))
-	return fmt.Sprintf("%x", h.Sum(nil))
 }
\ No newline at end of file
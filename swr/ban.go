@@ -0,0 +1,446 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Failed logins are counted per address and per account. Every failure doubles
+// the wait before the next try, and enough of them in a row locks the address
+// (or the account) out for a while. None of this survives a reboot, bans do.
+const (
+	LOGIN_BACKOFF_MAX  = 30 * time.Second
+	LOGIN_LOCKOUT_FAIL = 5
+	LOGIN_LOCKOUT      = 15 * time.Minute
+	LOGIN_FORGET       = time.Hour // a clean slate after this long without failing
+	LOGIN_TRIES        = 3         // per connection, then we hang up
+)
+
+const (
+	BAN_SITE   = "site"
+	BAN_PLAYER = "player"
+)
+
+// A ban on a site (CIDR) or a character. Characters also get Banned set in
+// their player file, the row here is for the reason and the expiry.
+type Ban struct {
+	gorm.Model
+	Kind    string `gorm:"index:idx_ban,unique"`
+	Target  string `gorm:"index:idx_ban,unique"`
+	Reason  string
+	By      string
+	Expires time.Time // zero means forever
+}
+
+func (b *Ban) Expired() bool {
+	return !b.Expires.IsZero() && time.Now().After(b.Expires)
+}
+
+func (b *Ban) Until() string {
+	if b.Expires.IsZero() {
+		return "forever"
+	}
+	return b.Expires.Local().Format("2006-01-02 15:04")
+}
+
+type login_failure struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+type BanService struct {
+	m        *sync.Mutex
+	sites    []*Ban
+	failures map[string]*login_failure
+}
+
+var _bans *BanService
+
+func Bans() *BanService {
+	if _bans == nil {
+		_bans = &BanService{
+			m:        &sync.Mutex{},
+			sites:    make([]*Ban, 0),
+			failures: make(map[string]*login_failure),
+		}
+		DB().db.AutoMigrate(&Ban{})
+		DB().db.Where("kind = ?", BAN_SITE).Find(&_bans.sites)
+		log.Printf("%d site bans loaded.", len(_bans.sites))
+	}
+	return _bans
+}
+
+// The host part of a remote address, for counting failures and checking bans.
+func addr_host(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// A bare address is a ban on just that address.
+func ban_cidr(site string) (*net.IPNet, bool) {
+	if !strings.Contains(site, "/") {
+		ip := net.ParseIP(site)
+		if ip == nil {
+			return nil, false
+		}
+		if ip.To4() != nil {
+			site += "/32"
+		} else {
+			site += "/128"
+		}
+	}
+	_, cidr, err := net.ParseCIDR(site)
+	if err != nil {
+		return nil, false
+	}
+	return cidr, true
+}
+
+// Durations like 30m, 12h, 7d or 2w. "perm" (or nothing) is forever.
+func ban_duration(str string) (time.Duration, bool) {
+	str = strings.ToLower(str)
+	if str == "perm" || str == "forever" {
+		return 0, true
+	}
+	mul := time.Duration(0)
+	switch {
+	case strings.HasSuffix(str, "d"):
+		mul = 24 * time.Hour
+	case strings.HasSuffix(str, "w"):
+		mul = 7 * 24 * time.Hour
+	}
+	if mul > 0 {
+		n, err := strconv.Atoi(str[:len(str)-1])
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return time.Duration(n) * mul, true
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// Is this address banned? Expired bans are cleaned out as we go.
+func (s *BanService) Site(host string) *Ban {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	for i := 0; i < len(s.sites); i++ {
+		ban := s.sites[i]
+		if ban.Expired() {
+			DB().db.Unscoped().Delete(ban)
+			s.sites = append(s.sites[:i], s.sites[i+1:]...)
+			i--
+			continue
+		}
+		if cidr, ok := ban_cidr(ban.Target); ok && cidr.Contains(ip) {
+			return ban
+		}
+	}
+	return nil
+}
+
+func (s *BanService) Player(name string) *Ban {
+	ban := new(Ban)
+	if DB().db.Where("kind = ? AND target = ?", BAN_PLAYER, strings.ToLower(name)).Limit(1).Find(ban).RowsAffected != 1 {
+		return nil
+	}
+	return ban
+}
+
+func (s *BanService) Add(ban *Ban) error {
+	ban.Target = strings.ToLower(ban.Target)
+	s.Remove(ban.Kind, ban.Target)
+	if err := DB().db.Create(ban).Error; err != nil {
+		return err
+	}
+	if ban.Kind == BAN_SITE {
+		s.m.Lock()
+		s.sites = append(s.sites, ban)
+		s.m.Unlock()
+	}
+	return nil
+}
+
+func (s *BanService) Remove(kind string, target string) bool {
+	target = strings.ToLower(target)
+	res := DB().db.Unscoped().Where("kind = ? AND target = ?", kind, target).Delete(&Ban{})
+	if kind == BAN_SITE {
+		s.m.Lock()
+		for i, ban := range s.sites {
+			if ban.Target == target {
+				s.sites = append(s.sites[:i], s.sites[i+1:]...)
+				break
+			}
+		}
+		s.m.Unlock()
+	}
+	return res.RowsAffected > 0
+}
+
+func (s *BanService) List() []*Ban {
+	bans := make([]*Ban, 0)
+	ErrorCheck(DB().db.Order("kind, target").Find(&bans).Error)
+	return bans
+}
+
+// How long before this address, or this account, may try again. Locked says
+// it's a lockout and not worth waiting around for.
+func (s *BanService) LoginWait(host string, account string) (time.Duration, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	wait := time.Duration(0)
+	locked := false
+	for _, key := range []string{"ip:" + host, "account:" + strings.ToLower(account)} {
+		f, ok := s.failures[key]
+		if !ok {
+			continue
+		}
+		if time.Since(f.last) > LOGIN_FORGET {
+			delete(s.failures, key)
+			continue
+		}
+		d := time.Until(f.until)
+		if d > wait {
+			wait = d
+		}
+		if d > 0 && f.count >= LOGIN_LOCKOUT_FAIL {
+			locked = true
+		}
+	}
+	return wait, locked
+}
+
+func (s *BanService) LoginFailed(host string, account string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	now := time.Now()
+	keys := []string{"ip:" + host}
+	if account != "" {
+		keys = append(keys, "account:"+strings.ToLower(account))
+	}
+	for _, key := range keys {
+		f, ok := s.failures[key]
+		if !ok || now.Sub(f.last) > LOGIN_FORGET {
+			f = &login_failure{}
+			s.failures[key] = f
+		}
+		f.count++
+		f.last = now
+		if f.count >= LOGIN_LOCKOUT_FAIL {
+			f.until = now.Add(LOGIN_LOCKOUT)
+			Log(LOG_AUTH).Wiz(WIZ_BANS).Warn("login lockout", "key", key, "failures", f.count)
+			continue
+		}
+		backoff := time.Second << (f.count - 1)
+		if backoff > LOGIN_BACKOFF_MAX {
+			backoff = LOGIN_BACKOFF_MAX
+		}
+		f.until = now.Add(backoff)
+	}
+}
+
+func (s *BanService) LoginOk(host string, account string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.failures, "ip:"+host)
+	delete(s.failures, "account:"+strings.ToLower(account))
+}
+
+// Turns away banned sites before they see the welcome screen.
+func ban_check_site(client Client) bool {
+	ban := Bans().Site(client.GetAddr())
+	if ban == nil {
+		return false
+	}
+	Log(LOG_NET).Wiz(WIZ_BANS).Info("refused connection from banned site", "addr", client.GetAddr(), "ban", ban.Target)
+	client.Send("\r\n&RYour site has been banned from this server.&d\r\n")
+	if ban.Reason != "" {
+		client.Sendf("&RReason: &W%s&d\r\n", ban.Reason)
+	}
+	client.Sendf("&RUntil: &W%s&d\r\n", ban.Until())
+	client.Close()
+	return true
+}
+
+// Is the character banned? Bans that ran out are lifted on the spot.
+func ban_check_player(client Client, player *PlayerProfile) bool {
+	if !player.Banned {
+		return false
+	}
+	ban := Bans().Player(player.Char.Name)
+	if ban != nil && ban.Expired() {
+		Bans().Remove(BAN_PLAYER, player.Char.Name)
+		player.Banned = false
+		DB().SavePlayerData(player)
+		return false
+	}
+	client.Sendf("\r\n&R%s has been banned.&d\r\n", player.Char.Name)
+	if ban != nil {
+		if ban.Reason != "" {
+			client.Sendf("&RReason: &W%s&d\r\n", ban.Reason)
+		}
+		client.Sendf("&RUntil: &W%s&d\r\n", ban.Until())
+	}
+	return true
+}
+
+// ban <character|ip|cidr> [duration] [reason]
+func do_ban(entity Entity, args ...string) {
+	if entity == nil || !entity.IsPlayer() {
+		return
+	}
+	if len(args) == 0 {
+		entity.Send("\r\nSyntax: ban <character|ip|cidr> [30m|12h|7d|2w|perm] [reason]\r\n")
+		return
+	}
+	ban := &Ban{Target: strings.ToLower(args[0]), By: entity.GetCharData().Name}
+	reason := args[1:]
+	if len(reason) > 0 {
+		if d, ok := ban_duration(reason[0]); ok {
+			if d > 0 {
+				ban.Expires = time.Now().Add(d)
+			}
+			reason = reason[1:]
+		}
+	}
+	ban.Reason = strings.Join(reason, " ")
+	if cidr, ok := ban_cidr(ban.Target); ok {
+		ban.Kind = BAN_SITE
+		ban.Target = cidr.String()
+		if err := Bans().Add(ban); err != nil {
+			ErrorCheck(err)
+			entity.Send("\r\n&RUnable to save the ban.&d\r\n")
+			return
+		}
+		for _, c := range DB().Clients() {
+			if cidr.Contains(net.ParseIP(c.GetAddr())) {
+				ban_check_site(c)
+			}
+		}
+	} else {
+		ban.Kind = BAN_PLAYER
+		var player *PlayerProfile
+		if e := DB().GetPlayerEntityByName(ban.Target); e != nil {
+			player = e.(*PlayerProfile)
+		} else if Storage().HasPlayer(ban.Target) {
+			player = DB().LoadPlayer(ban.Target)
+		}
+		if player == nil {
+			entity.Send("\r\n&RNo such character, or that isn't an address.&d\r\n")
+			return
+		}
+		if player.Priv >= entity.(*PlayerProfile).Priv {
+			entity.Send("\r\n&RYou can't ban them.&d\r\n")
+			return
+		}
+		if err := Bans().Add(ban); err != nil {
+			ErrorCheck(err)
+			entity.Send("\r\n&RUnable to save the ban.&d\r\n")
+			return
+		}
+		player.Banned = true
+		DB().SavePlayerData(player)
+		if DB().GetPlayerEntityByName(player.Char.Name) != nil {
+			DB().RemoveEntity(player)
+		}
+		if player.Client != nil {
+			ban_check_player(player.Client, player)
+			player.Client.Close()
+		}
+	}
+	Log(LOG_AUTH).Wiz(WIZ_BANS).Info(sprintf("%s banned %s %s", ban.By, ban.Kind, ban.Target), "until", ban.Until(), "reason", ban.Reason)
+	entity.Send("\r\n&YBanned %s until %s. Ok.&d\r\n", ban.Target, ban.Until())
+}
+
+func do_unban(entity Entity, args ...string) {
+	if entity == nil || !entity.IsPlayer() {
+		return
+	}
+	if len(args) != 1 {
+		entity.Send("\r\nSyntax: unban <character|ip|cidr>\r\n")
+		return
+	}
+	target := strings.ToLower(args[0])
+	if cidr, ok := ban_cidr(target); ok {
+		if !Bans().Remove(BAN_SITE, cidr.String()) {
+			entity.Send("\r\n&R%s isn't banned.&d\r\n", cidr.String())
+			return
+		}
+		target = cidr.String()
+	} else {
+		removed := Bans().Remove(BAN_PLAYER, target)
+		if Storage().HasPlayer(target) {
+			player := DB().LoadPlayer(target)
+			if player != nil && player.Banned {
+				player.Banned = false
+				DB().SavePlayerData(player)
+				removed = true
+			}
+		}
+		if !removed {
+			entity.Send("\r\n&R%s isn't banned.&d\r\n", target)
+			return
+		}
+	}
+	Log(LOG_AUTH).Wiz(WIZ_BANS).Info(sprintf("%s lifted the ban on %s", entity.GetCharData().Name, target))
+	entity.Send("\r\n&YUnbanned %s. Ok.&d\r\n", target)
+}
+
+func do_banlist(entity Entity, args ...string) {
+	if entity == nil || !entity.IsPlayer() {
+		return
+	}
+	bans := Bans().List()
+	entity.Send("\r\n%s\r\n", MakeTitle("Bans", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
+	if len(bans) == 0 {
+		entity.Send("&wNobody is banned.&d\r\n")
+		return
+	}
+	for _, ban := range bans {
+		expired := ""
+		if ban.Expired() {
+			expired = " &x(expired)"
+		}
+		entity.Send("&Y%-6s &W%-20s &wby &W%-12s &wuntil &W%s%s&d\r\n", ban.Kind, ban.Target, ban.By, ban.Until(), expired)
+		if ban.Reason != "" {
+			entity.Send("       &w%s&d\r\n", ban.Reason)
+		}
+	}
+}
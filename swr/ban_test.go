@@ -0,0 +1,173 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBanSite(t *testing.T) {
+	test_boot()
+	bans := []*Ban{
+		{Kind: BAN_SITE, Target: "192.0.2.0/24", Reason: "spam"},
+		{Kind: BAN_SITE, Target: "198.51.100.7/32"},
+		{Kind: BAN_SITE, Target: "2001:db8::/32"},
+		{Kind: BAN_SITE, Target: "203.0.113.9/32", Expires: time.Now().Add(-time.Minute)},
+		{Kind: BAN_SITE, Target: "203.0.113.10/32", Expires: time.Now().Add(time.Hour)},
+	}
+	for _, ban := range bans {
+		if err := Bans().Add(ban); err != nil {
+			t.Fatal(err)
+		}
+		defer Bans().Remove(BAN_SITE, ban.Target)
+	}
+	cases := []struct {
+		host string
+		ban  string
+	}{
+		{"192.0.2.1", "192.0.2.0/24"},
+		{"192.0.2.255", "192.0.2.0/24"},
+		{"192.0.3.1", ""},
+		{"198.51.100.7", "198.51.100.7/32"},
+		{"198.51.100.8", ""},
+		{"2001:db8::1", "2001:db8::/32"},
+		{"2001:db9::1", ""},
+		{"203.0.113.9", ""}, // ran out
+		{"203.0.113.10", "203.0.113.10/32"},
+		{"not an address", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		got := ""
+		if ban := Bans().Site(c.host); ban != nil {
+			got = ban.Target
+		}
+		if got != c.ban {
+			t.Errorf("%q: banned by %q, wanted %q", c.host, got, c.ban)
+		}
+	}
+	// the expired one was cleaned out on the way past
+	for _, ban := range Bans().List() {
+		if ban.Target == "203.0.113.9/32" {
+			t.Error("expired ban is still saved")
+		}
+	}
+}
+
+func TestBanCIDR(t *testing.T) {
+	cases := []struct {
+		site string
+		cidr string
+		ok   bool
+	}{
+		{"10.0.0.1", "10.0.0.1/32", true},
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"10.1.2.3/8", "10.0.0.0/8", true},
+		{"::1", "::1/128", true},
+		{"luke", "", false},
+		{"10.0.0.0/33", "", false},
+	}
+	for _, c := range cases {
+		cidr, ok := ban_cidr(c.site)
+		if ok != c.ok {
+			t.Errorf("%s: got %v, wanted %v", c.site, ok, c.ok)
+			continue
+		}
+		if ok && cidr.String() != c.cidr {
+			t.Errorf("%s: got %s, wanted %s", c.site, cidr, c.cidr)
+		}
+	}
+}
+
+func TestBanDuration(t *testing.T) {
+	cases := []struct {
+		str string
+		d   time.Duration
+		ok  bool
+	}{
+		{"perm", 0, true},
+		{"forever", 0, true},
+		{"30m", 30 * time.Minute, true},
+		{"12h", 12 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"2w", 14 * 24 * time.Hour, true},
+		{"0d", 0, false},
+		{"-5m", 0, false},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		d, ok := ban_duration(c.str)
+		if d != c.d || ok != c.ok {
+			t.Errorf("%s: got %v %v, wanted %v %v", c.str, d, ok, c.d, c.ok)
+		}
+	}
+}
+
+// Each failure doubles the wait, then it's a lockout, and a good login or an
+// hour of quiet forgets all about it.
+func TestLoginBackoff(t *testing.T) {
+	test_boot()
+	s := &BanService{m: &sync.Mutex{}, failures: make(map[string]*login_failure)}
+	host := "192.0.2.50"
+	if wait, locked := s.LoginWait(host, "luke"); wait != 0 || locked {
+		t.Fatalf("waiting %v (locked %v) before failing at all", wait, locked)
+	}
+	for n := 1; n < LOGIN_LOCKOUT_FAIL; n++ {
+		s.LoginFailed(host, "luke")
+		wait, locked := s.LoginWait(host, "luke")
+		want := time.Second << (n - 1)
+		if wait > want || wait < want-time.Second {
+			t.Errorf("failure %d: waiting %v, wanted about %v", n, wait, want)
+		}
+		if locked {
+			t.Errorf("failure %d: locked out already", n)
+		}
+	}
+	s.LoginFailed(host, "luke")
+	wait, locked := s.LoginWait(host, "luke")
+	if !locked || wait < LOGIN_LOCKOUT-time.Second {
+		t.Errorf("failure %d: waiting %v (locked %v), wanted a %v lockout", LOGIN_LOCKOUT_FAIL, wait, locked, LOGIN_LOCKOUT)
+	}
+	// the account stays locked from somewhere else, the address for anyone
+	if _, locked := s.LoginWait("192.0.2.51", "luke"); !locked {
+		t.Error("account isn't locked from another address")
+	}
+	if _, locked := s.LoginWait(host, "leia"); !locked {
+		t.Error("address isn't locked for another account")
+	}
+	if _, locked := s.LoginWait("192.0.2.51", "leia"); locked {
+		t.Error("somebody else entirely is locked out")
+	}
+
+	s.LoginOk(host, "luke")
+	if wait, locked := s.LoginWait(host, "luke"); wait != 0 || locked {
+		t.Errorf("waiting %v (locked %v) after logging in", wait, locked)
+	}
+
+	// forgotten after an hour without failing
+	s.LoginFailed(host, "")
+	s.failures["ip:"+host].last = time.Now().Add(-LOGIN_FORGET - time.Minute)
+	if wait, _ := s.LoginWait(host, ""); wait != 0 {
+		t.Errorf("waiting %v an hour later", wait)
+	}
+	if _, ok := s.failures["ip:"+host]; ok {
+		t.Error("old failures weren't forgotten")
+	}
+}
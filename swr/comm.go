@@ -74,7 +74,9 @@ var CommandFuncs = map[string]func(Entity, ...string){
 	"do_time":           do_time,
 	"do_levels":         do_levels,
 	"do_board_ship":     do_board_ship,
-	"do_leave_Ship":     do_leave_ship,
+	"do_leave_ship":     do_leave_ship,
+	"do_alias":          do_alias,
+	"do_unalias":        do_unalias,
 }
 var GMCommandFuncs = map[string]func(Entity, ...string){
 	"do_area_create":    do_area_create,
@@ -106,7 +108,11 @@ var GMCommandFuncs = map[string]func(Entity, ...string){
 	"do_transfer":       do_transfer,
 	"do_advance":        do_advance,
 	"do_dig":            do_dig,
+	"do_ban":            do_ban,
+	"do_unban":          do_unban,
+	"do_banlist":        do_banlist,
 	"do_editor":         do_editor,
+	"do_wiznet":         do_wiznet,
 }
 
 var Commands []*Command = make([]*Command, 0)
@@ -116,6 +122,39 @@ type Command struct {
 	Keywords []string `yaml:"keywords,flow"`
 	Level    uint     `yaml:"level"`
 	Func     string   `yaml:"func"`
+	Lag      uint     `yaml:"lag,omitempty"`      // pulses before the next command runs, see [PULSE]
+	Priority int      `yaml:"priority,omitempty"` // wins over other commands the same abbreviation could mean
+	Position string   `yaml:"position,omitempty"` // the least you have to be up to, sleeping, sitting, standing...
+	NoFight  bool     `yaml:"no_fight,omitempty"` // not while fighting
+	NoPilot  bool     `yaml:"no_pilot,omitempty"` // not while flying or gunning
+	Mob      bool     `yaml:"mob,omitempty"`      // mobs can use it too
+	Log      string   `yaml:"log,omitempty"`      // always, never, or immortals only when empty
+}
+
+const (
+	COMMAND_LOG_NORMAL = ""       // immortals only, a trail of what the staff got up to
+	COMMAND_LOG_ALWAYS = "always" // whoever uses it
+	COMMAND_LOG_NEVER  = "never"  // not even immortals, passwords and the like
+
+	POSITION_STANDING = "standing"
+)
+
+// From flat on your back (or worse) up to on your feet, for Command.Position.
+var positions = []string{
+	ENTITY_STATE_DEAD,
+	ENTITY_STATE_UNCONSCIOUS,
+	ENTITY_STATE_SLEEPING,
+	ENTITY_STATE_SITTING,
+	POSITION_STANDING,
+}
+
+func position_rank(state string) int {
+	for i, p := range positions {
+		if state == p {
+			return i
+		}
+	}
+	return len(positions) - 1 // fighting, piloting and the rest are all on their feet
 }
 
 func CommandsLoad() {
@@ -125,6 +164,7 @@ func CommandsLoad() {
 	err = yaml.Unmarshal(fp, &Commands)
 	ErrorCheck(err)
 	log.Printf("%d commands successfully loaded.", len(Commands))
+	command_check()
 }
 func command_map_to_func(name string) func(Entity, ...string) {
 	if k, ok := CommandFuncs[name]; ok {
@@ -135,55 +175,188 @@ func command_map_to_func(name string) func(Entity, ...string) {
 	}
 	return do_nothing
 }
-func command_fuzzy_match(command string) []Command {
-	ret := []Command{}
+
+// Everything input could be short for that the entity is allowed to run (mobs
+// get the mob commands, whatever their level), best first: exact keywords,
+// then the higher priority, then whatever comes first in commands.yml. The
+// bool is whether the best one won by a coin toss, a prefix of two commands
+// with the same priority.
+func command_match(entity Entity, input string) ([]*Command, bool) {
+	input = strings.ToLower(input)
+	if input == "" {
+		return nil, false
+	}
+	level := entity.GetCharData().Level
+	ret := make([]*Command, 0)
+	exact := make(map[*Command]bool)
 	for _, com := range Commands {
+		if entity.IsPlayer() && com.Level > level {
+			continue
+		}
+		if !entity.IsPlayer() && !com.Mob {
+			continue
+		}
 		for _, keyword := range com.Keywords {
-			if len(keyword) < len(command) {
-				continue
-			}
-			match := true
-			for i, r := range command {
-				if keyword[i] != byte(r) {
-					match = false
+			if strings.HasPrefix(keyword, input) {
+				if keyword == input {
+					exact[com] = true
+				}
+				if len(ret) == 0 || ret[len(ret)-1] != com {
+					ret = append(ret, com)
 				}
 			}
-			if match {
-				ret = append(ret, *com)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if exact[ret[i]] != exact[ret[j]] {
+			return exact[ret[i]]
+		}
+		return ret[i].Priority > ret[j].Priority
+	})
+	ambiguous := len(ret) > 1 && !exact[ret[0]] && ret[0].Priority == ret[1].Priority
+	return ret, ambiguous
+}
+
+// Boot time sanity check of commands.yml, keywords that belong to more than
+// one command, and commands without a function behind them.
+func command_check() {
+	owners := make(map[string][]*Command)
+	keywords := make([]string, 0)
+	for _, com := range Commands {
+		if com.Position != "" && !slice_contains_string(positions, com.Position) {
+			log.Printf("Command %s wants position %s, which isn't one of %s.", com.Name, com.Position, strings.Join(positions, ", "))
+		}
+		if com.Log != COMMAND_LOG_NORMAL && com.Log != COMMAND_LOG_ALWAYS && com.Log != COMMAND_LOG_NEVER {
+			log.Printf("Command %s has unknown log level %s.", com.Name, com.Log)
+		}
+		if _, ok := CommandFuncs[com.Func]; !ok {
+			if _, ok := GMCommandFuncs[com.Func]; !ok {
+				log.Printf("Command %s calls %s, which doesn't exist.", com.Name, com.Func)
+			}
+		}
+		for _, keyword := range com.Keywords {
+			coms, ok := owners[keyword]
+			if !ok {
+				keywords = append(keywords, keyword)
+			}
+			if len(coms) == 0 || coms[len(coms)-1] != com {
+				owners[keyword] = append(coms, com)
 			}
 		}
 	}
-	return ret
+	// the same way command_match picks, higher priority and then the first one
+	for _, keyword := range keywords {
+		coms := owners[keyword]
+		if len(coms) < 2 {
+			continue
+		}
+		win := coms[0]
+		for _, com := range coms[1:] {
+			if com.Priority > win.Priority {
+				win = com
+			}
+		}
+		names := make([]string, 0, len(coms))
+		for _, com := range coms {
+			names = append(names, com.Name)
+		}
+		log.Printf("Keyword %s belongs to %s, %s wins.", keyword, strings.Join(names, " and "), win.Name)
+	}
+}
+
+// Can the entity run the command the way they are right now? Tells them why
+// not if they can't.
+func command_allowed(entity Entity, com *Command) bool {
+	ch := entity.GetCharData()
+	if com.Position != "" && position_rank(ch.State) < position_rank(com.Position) {
+		switch ch.State {
+		case ENTITY_STATE_DEAD:
+			entity.Send("\r\n&RLie still, you are *DEAD*.&d\r\n")
+		case ENTITY_STATE_UNCONSCIOUS:
+			entity.Send("\r\n&dYou are unconscious.&d\r\n")
+		case ENTITY_STATE_SLEEPING:
+			entity.Send("\r\n&cIn your dreams?...&d\r\n")
+		default:
+			entity.Send("\r\n&dYou'll have to stand up first.&d\r\n")
+		}
+		return false
+	}
+	if com.NoFight && entity.IsFighting() {
+		entity.Send("\r\n&RNot while you're fighting!&d\r\n")
+		return false
+	}
+	if com.NoPilot && (ch.State == ENTITY_STATE_PILOTING || ch.State == ENTITY_STATE_GUNNING) {
+		entity.Send("\r\n&dNot while you're at the controls.&d\r\n")
+		return false
+	}
+	return true
+}
+
+func command_log(entity Entity, com *Command, args []string) {
+	switch com.Log {
+	case COMMAND_LOG_NEVER:
+		return
+	case COMMAND_LOG_NORMAL:
+		if !entity.IsPlayer() || entity.(*PlayerProfile).Priv < 100 {
+			return
+		}
+	}
+	Log(LOG_GAME).Info("command", "entity", entity.GetCharData().Name, "input", strings.Join(args, " "))
 }
+
+// 'hello is say hello and so on, through commands.yml like the rest so the
+// position and such still apply.
+var command_shortcuts = []struct {
+	prefix  string
+	keyword string
+}{
+	{"'", "say"},
+	{"\"", "comsay"},
+	{".", "emote"},
+}
+
 func do_command(entity Entity, input string) {
+	defer func() {
+		if recover_panic(recover(), "command", "entity", entity.GetCharData().Name, "input", input) {
+			entity.Send("\r\n&RSomething went wrong there, the immortals have been told.&d\r\n")
+		}
+	}()
 	args := strings.Split(input, " ")
 	if entity.IsPlayer() && input == "!" {
 		player := entity.(*PlayerProfile)
 		args = strings.Split(player.LastCommand, " ")
 	}
-	if strings.HasPrefix(args[0], "'") {
-		args[0] = strings.TrimPrefix(args[0], "'")
-		do_say(entity, args...)
-		entity.Prompt()
-	} else if strings.HasPrefix(args[0], "\"") {
-		args[0] = strings.TrimPrefix(args[0], "\"")
-		do_say_comlink(entity, args...)
-		entity.Prompt()
-	} else if strings.HasPrefix(args[0], ".") {
-		args[0] = strings.TrimPrefix(args[0], ".")
-		do_emote(entity, args...)
+	for _, sc := range command_shortcuts {
+		if strings.HasPrefix(args[0], sc.prefix) {
+			args[0] = strings.TrimPrefix(args[0], sc.prefix)
+			args = append([]string{sc.keyword}, args...)
+			break
+		}
+	}
+	commands, ambiguous := command_match(entity, args[0])
+	if ambiguous && entity.IsPlayer() && entity.(*PlayerProfile).Priv >= 100 {
+		names := make([]string, 0)
+		for _, com := range commands {
+			if com.Priority == commands[0].Priority {
+				names = append(names, com.Name)
+			}
+		}
+		entity.Send("\r\n&x(%s could be %s, went with %s)&d\r\n", args[0], strings.Join(names, ", "), commands[0].Name)
+	}
+	if len(commands) > 0 {
+		com := commands[0]
+		if command_allowed(entity, com) {
+			command_log(entity, com, args)
+			command_map_to_func(com.Func)(entity, args[1:]...)
+			if com.Lag > 0 {
+				entity_wait(entity, int(com.Lag))
+			}
+		}
 		entity.Prompt()
 	} else {
-		commands := command_fuzzy_match(args[0])
-		if len(commands) > 0 && commands[0].Level <= entity.GetCharData().Level {
-			a := args[1:]
-			command_map_to_func(commands[0].Func)(entity, a...)
+		if entity.IsPlayer() {
+			entity.Send("\r\nHuh?\r\n")
 			entity.Prompt()
-		} else {
-			if entity.IsPlayer() {
-				entity.Send("\r\nHuh?\r\n")
-				entity.Prompt()
-			}
 		}
 	}
 	if entity.IsPlayer() {
@@ -202,7 +375,7 @@ func do_commands(entity Entity, args ...string) {
 	if !entity.IsPlayer() {
 		return
 	}
-	entity.Send("\r\n%s\r\n", MakeTitle("Commands", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER))
+	entity.Send("\r\n%s\r\n", MakeTitle("Commands", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
 	entity.Send("&wFor more information, type &yhelp &Y<command>&d\r\n")
 	c := make([]string, 0)
 	for _, com := range Commands {
@@ -0,0 +1,107 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+var comm_test_commands = []*Command{
+	{Name: "south", Keywords: []string{"south", "s"}, Priority: 10, Mob: true},
+	{Name: "say", Keywords: []string{"say"}, Priority: 5, Mob: true},
+	{Name: "score", Keywords: []string{"score"}},
+	{Name: "sacrifice", Keywords: []string{"sacrifice", "sac"}},
+	{Name: "shout", Keywords: []string{"shout"}, Priority: 5},
+	{Name: "shutdown", Keywords: []string{"shutdown"}, Level: 100, Priority: 50},
+	{Name: "sneak", Keywords: []string{"sneak"}},
+	{Name: "snipe", Keywords: []string{"snipe"}},
+	{Name: "look", Keywords: []string{"look", "l"}, Mob: true},
+	{Name: "list", Keywords: []string{"list", "l"}, Priority: 1},
+}
+
+func TestCommandMatch(t *testing.T) {
+	orig := Commands
+	defer func() { Commands = orig }()
+	Commands = comm_test_commands
+
+	mortal := new(PlayerProfile)
+	mortal.Char.Level = 1
+	immortal := new(PlayerProfile)
+	immortal.Char.Level = 100
+	mob := new(CharData)
+
+	cases := []struct {
+		name      string
+		entity    Entity
+		input     string
+		want      string
+		ambiguous bool
+	}{
+		{"exact", mortal, "score", "score", false},
+		{"exact beats priority", mortal, "s", "south say shout score sacrifice sneak snipe", false},
+		{"exact beats priority", mortal, "sac", "sacrifice", false},
+		{"priority", mortal, "sa", "say sacrifice", false},
+		{"priority", mortal, "l", "list look", false},
+		{"coin toss", mortal, "sn", "sneak snipe", true},
+		{"level", mortal, "sh", "shout", false},
+		{"level", immortal, "sh", "shutdown shout", false},
+		{"case", mortal, "SCO", "score", false},
+		{"nothing", mortal, "xyzzy", "", false},
+		{"nothing", mortal, "", "", false},
+		{"mob", mob, "s", "south say", false},
+		{"mob", mob, "sh", "", false},
+	}
+	for _, c := range cases {
+		coms, ambiguous := command_match(c.entity, c.input)
+		got := make([]string, 0, len(coms))
+		for _, com := range coms {
+			got = append(got, com.Name)
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%s %q: got %v, wanted %s", c.name, c.input, got, c.want)
+		}
+		if ambiguous != c.ambiguous {
+			t.Errorf("%s %q: ambiguous %v, wanted %v", c.name, c.input, ambiguous, c.ambiguous)
+		}
+	}
+}
+
+// The boot check names the command that really gets the keyword.
+func TestCommandCheck(t *testing.T) {
+	orig := Commands
+	defer func() { Commands = orig }()
+	Commands = []*Command{
+		{Name: "look", Keywords: []string{"look", "l"}, Func: "do_look"},
+		{Name: "list", Keywords: []string{"list", "l"}, Func: "do_look", Priority: 1},
+		{Name: "lock", Keywords: []string{"lock", "lo"}, Func: "do_look"},
+		{Name: "lower", Keywords: []string{"lower", "lo"}, Func: "do_look"},
+	}
+	var buf bytes.Buffer
+	out := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(out)
+	command_check()
+	for _, want := range []string{"Keyword l belongs to look and list, list wins.", "Keyword lo belongs to lock and lower, lock wins."} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("wanted %q in %q", want, buf.String())
+		}
+	}
+}
@@ -26,10 +26,22 @@ import (
 )
 
 type Configuration struct {
-	Name string `yaml:"name"`
-	Data string `yaml:"data"`
-	Addr string `yaml:"addr"`
-	Salt string `yaml:"salt"`
+	Name        string      `yaml:"name"`
+	Data        string      `yaml:"data"`
+	Addr        string      `yaml:"addr"`
+	WebAddr     string      `yaml:"web_addr,omitempty"`        // http/websocket listener for the web client, empty to disable
+	SSHAddr     string      `yaml:"ssh_addr,omitempty"`        // ssh listener, empty to disable
+	Proxies     []string    `yaml:"trusted_proxies,omitempty"` // addresses or CIDRs whose X-Forwarded-For/X-Real-IP the web listener believes
+	Salt        string      `yaml:"salt"`                      // pepper for password hashes, changing it invalidates every password
+	Mail        MailConfig  `yaml:"mail,omitempty"`
+	Linkdead    uint        `yaml:"linkdead,omitempty"`  // seconds a dropped player stays in the world, 0 for the default
+	Creation    string      `yaml:"creation,omitempty"`  // how new characters get their stats, roll (the default) or pointbuy
+	PointBuy    int         `yaml:"point_buy,omitempty"` // points to spend with pointbuy, 0 for the default
+	Rerolls     int         `yaml:"rerolls,omitempty"`   // rerolls allowed with roll, 0 for no limit
+	Log         LogConfig   `yaml:"log,omitempty"`
+	OnLoadError string      `yaml:"on_load_error,omitempty"` // quarantine (the default) or refuse, when game data is broken at boot
+	Storage     string      `yaml:"storage,omitempty"`       // where players, ships and mail are kept, yaml (the default) or sqlite
+	World       WorldConfig `yaml:"world,omitempty"`
 }
 
 var _config *Configuration
@@ -0,0 +1,188 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// How new characters get their stats, `creation` in config.yml. Either the
+// classic roll (optionally with a limited number of rerolls) or a pool of
+// points spread over the stats by hand.
+const (
+	CREATION_ROLL      = "roll"
+	CREATION_POINT_BUY = "pointbuy"
+)
+
+const (
+	POINT_BUY_BASE = 8  // every stat starts here before race modifiers
+	POINT_BUY_POOL = 30 // points to spend, unless config.yml says otherwise
+)
+
+var stat_names = []string{"STR", "INT", "DEX", "WIS", "CON", "CHA"}
+
+func stat_index(name string) int {
+	for i, s := range stat_names {
+		if strings.EqualFold(s, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func point_buy_pool() int {
+	if Config().PointBuy > 0 {
+		return Config().PointBuy
+	}
+	return POINT_BUY_POOL
+}
+
+func stats_line(stats []int) string {
+	buf := ""
+	for i, s := range stat_names {
+		buf += fmt.Sprintf("&Y%s: &w%-2d  ", s, stats[i])
+	}
+	return strings.TrimRight(buf, " ")
+}
+
+// What the stats work out to for a fresh level 1 character of the race.
+func stats_preview(race *RaceData, stats []int) string {
+	ch := &CharData{Race: race.Name, Level: 1, Stats: stats}
+	return fmt.Sprintf("&GCarry: &W%d kg  &GItems: &W%d  &GArmor: &W%d&d", ch.MaxWeight(), ch.MaxInventoryCount(), ch.ArmorAC())
+}
+
+func auth_do_stats(client Client, race *RaceData) []int {
+	if Config().Creation == CREATION_POINT_BUY {
+		return auth_do_point_buy(client, race)
+	}
+	return auth_do_roll_stats(client, race)
+}
+
+// The old way, roll until you like them or run out of rerolls. nil if the
+// client went away.
+func auth_do_roll_stats(client Client, race *RaceData) []int {
+	rerolls := Config().Rerolls
+	for roll := 0; ; roll++ {
+		stats := race.ApplyStats(roll_stats())
+		client.Sendf("\r\n\r\n%s\r\n%s\r\n\r\n", stats_line(stats), stats_preview(race, stats))
+		if rerolls > 0 && roll >= rerolls {
+			client.Send("&GThat was your last reroll, these are your stats.&d\r\n")
+			return stats
+		}
+		if rerolls > 0 {
+			client.Sendf("&GAre these ok? &G[&Wy&G/&Wn&G] &x(%d rerolls left)&d ", rerolls-roll)
+		} else {
+			client.Send("&GAre these ok? &G[&Wy&G/&Wn&G]&d ")
+		}
+		answer := client.Read()
+		if client.IsClosed() {
+			return nil
+		}
+		if strings.HasPrefix(strings.ToLower(answer), "y") {
+			return stats
+		}
+	}
+}
+
+// Spend a pool of points on the stats, one point per point, within what the
+// race allows. Points can be taken back but a stat can't be sold below where it
+// started for more, so there's never more than the pool to spend. nil if the
+// client went away.
+func auth_do_point_buy(client Client, race *RaceData) []int {
+	pool := point_buy_pool()
+	base := make([]int, 6)
+	start := make([]int, 6)
+reset:
+	left := pool
+	for i := range base {
+		// races that can't go as low as the base (or as high) start at their limit for free
+		base[i] = POINT_BUY_BASE
+		if base[i]+race.Stats[i] < race.Min[i] {
+			base[i] = race.Min[i] - race.Stats[i]
+		}
+		if base[i]+race.Stats[i] > race.Max[i] {
+			base[i] = race.Max[i] - race.Stats[i]
+		}
+		start[i] = base[i]
+	}
+	client.Sendf("\r\n&GYou have &W%d&G points to spend. Type a stat and how much to add or take away (&Wstr +2&G, &Wdex -1&G),\r\n&Wreset&G to start over, or &Wdone&G when you are happy.&d\r\n", pool)
+	for {
+		stats := race.ApplyStats(base)
+		client.Sendf("\r\n%s\r\n", stats_line(stats))
+		buf := "&GLimits: "
+		for i, s := range stat_names {
+			buf += fmt.Sprintf("&x%s &w%d-%d  ", s, race.Min[i], race.Max[i])
+		}
+		client.Sendf("%s&d\r\n%s\r\n&GPoints left: &W%d&d\r\n&G>&d ", strings.TrimRight(buf, " "), stats_preview(race, stats), left)
+		input := client.Read()
+		if client.IsClosed() {
+			return nil
+		}
+		args := strings.Fields(strings.ToLower(input))
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "reset":
+			goto reset
+		case "done":
+			if left > 0 {
+				client.Sendf("&GYou still have &W%d&G points left, are you sure? [&Wy&G/&Wn&G]&d ", left)
+				if !strings.HasPrefix(strings.ToLower(client.Read()), "y") {
+					continue
+				}
+			}
+			client.Sendf("\r\n%s\r\n&GAre these ok? &G[&Wy&G/&Wn&G]&d ", stats_line(stats))
+			answer := client.Read()
+			if client.IsClosed() {
+				return nil
+			}
+			if strings.HasPrefix(strings.ToLower(answer), "y") {
+				return stats
+			}
+			continue
+		}
+		i := stat_index(args[0])
+		if i < 0 || len(args) < 2 {
+			client.Send("}RTry &Wstr +2&R, &Wdex -1&R, &Wreset&R or &Wdone&R.&d\r\n")
+			continue
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n == 0 {
+			client.Send("}RHow many points?&d\r\n")
+			continue
+		}
+		value := base[i] + n + race.Stats[i]
+		if value < race.Min[i] || value > race.Max[i] {
+			client.Sendf("}R%s has to stay between %d and %d for a %s.&d\r\n", stat_names[i], race.Min[i], race.Max[i], race.Name)
+			continue
+		}
+		if base[i]+n < start[i] {
+			client.Sendf("}RYou can only take back the %d points you put into %s.&d\r\n", base[i]-start[i], stat_names[i])
+			continue
+		}
+		if n > left {
+			client.Sendf("}RYou only have %d points left.&d\r\n", left)
+			continue
+		}
+		base[i] += n
+		left -= n
+	}
+}
@@ -0,0 +1,67 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Taking points back out of a stat stops where it started, selling it off
+// below that would hand out more than the pool.
+func TestPointBuy(t *testing.T) {
+	test_boot()
+	race := *race_playable()[0]
+	race.Stats = []int{0, 0, 0, 0, 0, 0}
+	race.Min = []int{3, 3, 3, 3, 3, 3}
+	race.Max = []int{50, 50, 50, 50, 50, 50}
+	pool := point_buy_pool()
+
+	client := new_test_client("pointbuy", []string{
+		"str -1", // nothing put in yet
+		"str +3",
+		"str -5", // only 3 to take back
+		"str -3", // fine
+		"dex -2", // nothing put in
+		"int +" + strconv.Itoa(pool),
+		"con +1", // none left
+		"int -1",
+		"con +1",
+		"done",
+		"y",
+	})
+	// out of script and still asking, hang up
+	timeout := time.AfterFunc(10*time.Second, client.Close)
+	defer timeout.Stop()
+	stats := auth_do_point_buy(client, &race)
+	if stats == nil {
+		t.Fatal("still spending points after the script ran out")
+	}
+	want := []int{POINT_BUY_BASE, POINT_BUY_BASE + pool - 1, POINT_BUY_BASE, POINT_BUY_BASE, POINT_BUY_BASE + 1, POINT_BUY_BASE}
+	spent := 0
+	for i := range stats {
+		if stats[i] != want[i] {
+			t.Errorf("%s is %d, wanted %d", stat_names[i], stats[i], want[i])
+		}
+		spent += stats[i] - POINT_BUY_BASE
+	}
+	if spent != pool {
+		t.Errorf("spent %d points out of %d", spent, pool)
+	}
+}
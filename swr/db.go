@@ -41,7 +41,7 @@ type HelpData struct {
 }
 
 type GameDatabase struct {
-	m               *sync.Mutex
+	m               *sync.RWMutex // writers add and remove, everybody else reads a snapshot
 	db              *gorm.DB
 	clients         []Client
 	entities        []Entity
@@ -55,22 +55,14 @@ type GameDatabase struct {
 	helps           []*HelpData
 }
 
-// TODO: Flesh out the interfaces and wrap the GameDatabase behind this...
-type Database interface {
-	SaveArea(area *AreaData) error
-	SaveEntity(entity Entity) error
-	SaveObject(object Item) error
-	SaveShip(ship Ship) error
-}
-
 func DB() *GameDatabase {
 	if _db == nil {
 		log.Printf("Starting Database.")
 		db, e := gorm.Open(sqlite.Open("data/game.db"), &gorm.Config{})
 		ErrorCheck(e)
-		db.AutoMigrate(&Account{})
+		db.AutoMigrate(&Account{}, &PlayerRef{})
 		_db = new(GameDatabase)
-		_db.m = &sync.Mutex{}
+		_db.m = &sync.RWMutex{}
 		_db.db = db
 		_db.clients = make([]Client, 0, 64)
 		_db.entities = make([]Entity, 0)
@@ -95,12 +87,21 @@ func (d *GameDatabase) Unlock() {
 	d.m.Unlock()
 }
 
+func (d *GameDatabase) RLock() {
+	d.m.RLock()
+}
+
+func (d *GameDatabase) RUnlock() {
+	d.m.RUnlock()
+}
+
 func (d *GameDatabase) AddClient(client Client) {
 	d.Lock()
 	defer d.Unlock()
 	d.clients = append(d.clients, client)
 }
 
+// Removes the client and the player using it.
 func (d *GameDatabase) RemoveClient(client Client) {
 	d.Lock()
 	defer d.Unlock()
@@ -112,11 +113,24 @@ func (d *GameDatabase) RemoveClient(client Client) {
 			p := e.(*PlayerProfile)
 			if p.Client != nil {
 				if p.Client == client {
-					d.RemoveEntity(e)
+					d.remove_entity(e)
+					break
 				}
 			}
 		}
 	}
+	d.remove_client(client)
+}
+
+// Removes just the client, the player stays (linkdead, or in somebody else's
+// hands).
+func (d *GameDatabase) DropClient(client Client) {
+	d.Lock()
+	defer d.Unlock()
+	d.remove_client(client)
+}
+
+func (d *GameDatabase) remove_client(client Client) {
 	index := -1
 	for i, c := range d.clients {
 		if c == nil {
@@ -127,7 +141,7 @@ func (d *GameDatabase) RemoveClient(client Client) {
 		}
 	}
 	if index > -1 {
-		ret := make([]Client, len(d.clients)-1)
+		ret := make([]Client, 0, len(d.clients)-1)
 		ret = append(ret, d.clients[:index]...)
 		ret = append(ret, d.clients[index+1:]...)
 		d.clients = ret
@@ -137,6 +151,11 @@ func (d *GameDatabase) RemoveClient(client Client) {
 func (d *GameDatabase) RemoveEntity(entity Entity) {
 	d.Lock()
 	defer d.Unlock()
+	d.remove_entity(entity)
+}
+
+// RemoveEntity for when we already hold the lock.
+func (d *GameDatabase) remove_entity(entity Entity) {
 	if entity == nil {
 		return
 	}
@@ -150,7 +169,7 @@ func (d *GameDatabase) RemoveEntity(entity Entity) {
 		}
 	}
 	if index > -1 {
-		ret := make([]Entity, len(d.entities)-1)
+		ret := make([]Entity, 0, len(d.entities)-1)
 		ret = append(ret, d.entities[:index]...)
 		ret = append(ret, d.entities[index+1:]...)
 		d.entities = ret
@@ -171,7 +190,7 @@ func (d *GameDatabase) RemoveShip(ship Ship) {
 		}
 	}
 	if index > -1 {
-		ret := make([]Ship, len(d.ships)-1)
+		ret := make([]Ship, 0, len(d.ships)-1)
 		ret = append(ret, d.ships[:index]...)
 		ret = append(ret, d.ships[index+1:]...)
 		d.ships = ret
@@ -220,6 +239,10 @@ func (d *GameDatabase) Load() {
 	// Load Ships
 	d.LoadShips()
 
+	// Load Races, they start out carrying items
+	RacesLoad()
+
+	d.Validate()
 }
 
 func (d *GameDatabase) LoadHelps() {
@@ -230,11 +253,11 @@ func (d *GameDatabase) LoadHelps() {
 	defer d.Unlock()
 	for _, help_file := range flist {
 		fpath := fmt.Sprintf("docs/%s", help_file.Name())
-		fp, err := os.ReadFile(fpath)
-		ErrorCheck(err)
 		help := new(HelpData)
-		err = yaml.Unmarshal(fp, help)
-		ErrorCheck(err)
+		if err := load_yaml(fpath, help); err != nil {
+			load_problem(err)
+			continue
+		}
 		d.helps = append(d.helps, help)
 	}
 	log.Printf("%d help files loaded.\n", len(flist))
@@ -247,20 +270,23 @@ func (d *GameDatabase) LoadAreas() {
 	count := 0
 	for _, area_file := range flist {
 		if strings.HasSuffix(area_file.Name(), "yml") {
-			d.LoadArea(area_file.Name())
+			if err := d.LoadArea(area_file.Name()); err != nil {
+				load_problem(err)
+				continue
+			}
 			count++
 		}
 	}
 	log.Printf("%d areas loaded.\n", count)
 }
 
-func (d *GameDatabase) LoadArea(name string) {
+func (d *GameDatabase) LoadArea(name string) error {
 	fpath := fmt.Sprintf("data/areas/%s", name)
-	fp, err := os.ReadFile(fpath)
-	ErrorCheck(err)
 	area := new(AreaData)
-	err = yaml.Unmarshal(fp, area)
-	ErrorCheck(err)
+	if err := load_yaml(fpath, area); err != nil {
+		return err
+	}
+	area.Filename = fpath
 	d.Lock()
 	defer d.Unlock()
 	for i := range area.Rooms {
@@ -270,6 +296,7 @@ func (d *GameDatabase) LoadArea(name string) {
 		time.Sleep(1 * time.Millisecond)
 	}
 	d.areas[area.Name] = area
+	return nil
 }
 
 func (d *GameDatabase) LoadPlanets() {
@@ -280,11 +307,11 @@ func (d *GameDatabase) LoadPlanets() {
 	defer d.Unlock()
 	for _, f := range flist {
 		fpath := fmt.Sprintf("data/planets/%s", f.Name())
-		fp, err := os.ReadFile(fpath)
-		ErrorCheck(err)
 		p := new(StarSystemData)
-		err = yaml.Unmarshal(fp, p)
-		ErrorCheck(err)
+		if err := load_yaml(fpath, p); err != nil {
+			load_problem(err)
+			continue
+		}
 		d.starsystems = append(d.starsystems, p)
 	}
 	log.Printf("%d total planets loaded.", len(d.starsystems))
@@ -299,7 +326,7 @@ func (d *GameDatabase) LoadItems() {
 			}
 			if !info.IsDir() {
 				if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
-					d.LoadItem(path)
+					load_problem(d.LoadItem(path))
 				}
 			}
 			return nil
@@ -308,16 +335,19 @@ func (d *GameDatabase) LoadItems() {
 	log.Printf("%d items loaded.", len(d.items))
 }
 
-func (d *GameDatabase) LoadItem(path string) {
-	fp, err := os.ReadFile(path)
-	ErrorCheck(err)
+func (d *GameDatabase) LoadItem(path string) error {
 	item := new(ItemData)
-	err = yaml.Unmarshal(fp, item)
-	ErrorCheck(err)
+	if err := load_yaml(path, item); err != nil {
+		return err
+	}
 	item.Filename = path
 	d.Lock()
 	defer d.Unlock()
+	if other, ok := d.items[item.Id]; ok && other.Filename != path {
+		load_problem(&LoadError{File: path, Field: "id", Err: Err("item %d is also in %s", item.Id, other.Filename)})
+	}
 	d.items[item.Id] = item
+	return nil
 }
 
 func (d *GameDatabase) LoadMobs() {
@@ -329,7 +359,7 @@ func (d *GameDatabase) LoadMobs() {
 			}
 			if !info.IsDir() {
 				if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
-					d.LoadMob(path)
+					load_problem(d.LoadMob(path))
 				}
 			}
 			return nil
@@ -337,72 +367,67 @@ func (d *GameDatabase) LoadMobs() {
 	ErrorCheck(err)
 	log.Printf("%d mobs loaded.", len(d.mobs))
 }
-func (d *GameDatabase) LoadMob(path string) {
-	fp, err := os.ReadFile(path)
-	ErrorCheck(err)
+func (d *GameDatabase) LoadMob(path string) error {
 	ch := new(CharData)
-	err = yaml.Unmarshal(fp, ch)
-	ErrorCheck(err)
+	if err := load_yaml(path, ch); err != nil {
+		return err
+	}
 	ch.Filename = path
 	d.Lock()
 	defer d.Unlock()
+	if other, ok := d.mobs[ch.Id]; ok && other.Filename != path {
+		load_problem(&LoadError{File: path, Field: "id", Err: Err("mob %d is also in %s", ch.Id, other.Filename)})
+	}
 	d.mobs[ch.Id] = ch
+	return nil
 }
 func (d *GameDatabase) LoadShips() {
 	log.Print("Loading ship files.")
-	err := filepath.Walk("data/ships",
+	err := filepath.Walk("data/ships/prototypes",
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 			if !info.IsDir() {
-				if strings.Contains(path, "prototype") {
-					if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
-						d.LoadShipPrototype(path)
-					}
-				} else {
-					if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
-						d.LoadShip(path)
-					}
+				if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+					load_problem(d.LoadShipPrototype(path))
 				}
 			}
 			return nil
 		})
 	ErrorCheck(err)
-	log.Printf("%d ships loaded. %d prototypes.", len(d.ships), len(d.ship_prototypes))
-}
-func (d *GameDatabase) LoadShip(path string) {
-	fp, err := os.ReadFile(path)
-	ErrorCheck(err)
-	ship := new(ShipData)
-	err = yaml.Unmarshal(fp, ship)
+	ships, err := Storage().LoadShips()
 	ErrorCheck(err)
 	d.Lock()
-	defer d.Unlock()
-	d.ships = append(d.ships, ship)
+	for _, ship := range ships {
+		d.ships = append(d.ships, ship)
+	}
+	d.Unlock()
+	log.Printf("%d ships loaded. %d prototypes.", len(d.ships), len(d.ship_prototypes))
 }
-func (d *GameDatabase) LoadShipPrototype(path string) {
-	fp, err := os.ReadFile(path)
-	ErrorCheck(err)
+func (d *GameDatabase) LoadShipPrototype(path string) error {
 	ship := new(ShipData)
-	err = yaml.Unmarshal(fp, ship)
-	ErrorCheck(err)
+	if err := load_yaml(path, ship); err != nil {
+		return err
+	}
 	d.Lock()
 	defer d.Unlock()
 	d.ship_prototypes[ship.Id] = ship
+	return nil
 }
 
 // The Mother of all save functions
 func (d *GameDatabase) Save() {
-	d.Lock()
-	defer d.Unlock()
 	echo_all("\r\n&xSaving Game World&d\r\n")
 	t := time.Now()
+	d.Lock()
 	d.SaveAreas()
 	d.SaveMobs()
 	d.SaveItems()
 	d.SaveShips()
 	d.SavePlayers()
+	ErrorCheck(Storage().SaveWorld(d.world_state()))
+	d.Unlock()
 	echo_all(sprintf("\r\n&xSave took %s&d\r\n", time.Since(t).String()))
 }
 
@@ -424,10 +449,7 @@ func (d *GameDatabase) SaveMobs() {
 
 func (d *GameDatabase) SaveShips() {
 	for _, ship := range d.ship_prototypes {
-		buf, err := yaml.Marshal(ship)
-		ErrorCheck(err)
-		err = os.WriteFile(sprintf("data/ships/prototypes/%s.yml", strings.ToLower(strings.ReplaceAll(ship.Type, " ", "_"))), buf, 0755)
-		ErrorCheck(err)
+		ErrorCheck(write_yaml(sprintf("data/ships/prototypes/%s.yml", strings.ToLower(strings.ReplaceAll(ship.Type, " ", "_"))), ship))
 	}
 	for _, ship := range d.ships {
 		d.SaveShip(ship)
@@ -452,16 +474,11 @@ func (d *GameDatabase) SavePlayers() {
 }
 
 func (d *GameDatabase) SaveShip(ship Ship) {
-	buf, err := yaml.Marshal(ship)
-	ErrorCheck(err)
-	err = os.WriteFile(sprintf("data/ships/%s.yml", strings.ToLower(strings.ReplaceAll(ship.GetData().Name, " ", "_"))), buf, 0755)
-	ErrorCheck(err)
+	ErrorCheck(Storage().SaveShip(ship.GetData()))
 }
 
 func (d *GameDatabase) SaveArea(area *AreaData) {
-	buf, err := yaml.Marshal(area)
-	ErrorCheck(err)
-	err = os.WriteFile(sprintf("data/areas/%s.yml", area.Name), buf, 0755)
+	ErrorCheck(write_yaml(sprintf("data/areas/%s.yml", area.Name), area))
 	for _, m := range area.Mobs {
 		mob := d.mobs[m.Mob]
 		d.SaveMob(mob)
@@ -470,28 +487,16 @@ func (d *GameDatabase) SaveArea(area *AreaData) {
 		item := d.items[i.Item]
 		d.SaveItem(item)
 	}
-	ErrorCheck(err)
 }
 func (d *GameDatabase) SaveItem(item *ItemData) {
-	buf, err := yaml.Marshal(item)
-	ErrorCheck(err)
-	dir := filepath.Dir(item.Filename)
-	os.MkdirAll(dir, 0755)
-	err = os.WriteFile(item.Filename, buf, 0755)
-	ErrorCheck(err)
+	ErrorCheck(write_yaml(item.Filename, item))
 }
 func (d *GameDatabase) SaveMob(mob *CharData) {
-	buf, err := yaml.Marshal(mob)
-	ErrorCheck(err)
-	dir := filepath.Dir(mob.Filename)
-	os.MkdirAll(dir, 0755)
-	err = os.WriteFile(mob.Filename, buf, 0755)
-	ErrorCheck(err)
+	ErrorCheck(write_yaml(mob.Filename, mob))
 }
 
 func (d *GameDatabase) GetPlayer(name string) *PlayerProfile {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
 	var player *PlayerProfile
 	for i := range d.entities {
 		e := d.entities[i]
@@ -503,45 +508,54 @@ func (d *GameDatabase) GetPlayer(name string) *PlayerProfile {
 			}
 		}
 	}
-	d.Unlock() // unlock early
+	d.RUnlock() // don't hold it while we hit the disk
 	// Player isn't online
 	if player == nil {
-		path := fmt.Sprintf("data/accounts/%s/%s.yml", strings.ToLower(name[0:1]), strings.ToLower(name))
-		player = d.ReadPlayerData(path)
+		player = d.LoadPlayer(name)
 	}
 	return player
 }
 
+// A player that isn't online, from wherever the storage keeps them. nil if
+// there's no such player or they can't be read.
+func (d *GameDatabase) LoadPlayer(name string) *PlayerProfile {
+	p_data, err := Storage().LoadPlayer(name)
+	if err != nil {
+		Log(LOG_DB).Error("can't read player", "name", name, "err", err)
+		return nil
+	}
+	player_loaded(p_data)
+	return p_data
+}
+
+// A player file by its path, accounts_migrate reads the old ones with it.
 func (d *GameDatabase) ReadPlayerData(filename string) *PlayerProfile {
-	fp, err := os.ReadFile(filename)
-	ErrorCheck(err)
 	p_data := new(PlayerProfile)
-	err = yaml.Unmarshal(fp, p_data)
-	if err != nil {
-		ErrorCheck(err)
+	if err := load_yaml(filename, p_data); err != nil {
+		Log(LOG_DB).Error("can't read player file", "err", err)
 		return nil
 	}
+	player_loaded(p_data)
+	return p_data
+}
+
+func player_loaded(p_data *PlayerProfile) {
 	if p_data.Char.Equipment == nil {
 		p_data.Char.Equipment = make(map[string]*ItemData)
 	}
 	if p_data.Char.Inventory == nil {
 		p_data.Char.Inventory = make([]*ItemData, 0)
 	}
-	return p_data
 }
 
 func (d *GameDatabase) SavePlayerData(player *PlayerProfile) {
-	name := strings.ToLower(player.Char.Name)
-	filename := fmt.Sprintf("data/accounts/%s/%s.yml", name[0:1], name)
-	buf, err := yaml.Marshal(player)
-	ErrorCheck(err)
-	err = os.WriteFile(filename, buf, 0755)
-	ErrorCheck(err)
+	ErrorCheck(Storage().SavePlayer(player))
+	Log(LOG_DB).Wiz(WIZ_SAVES).Debug(sprintf("%s saved", player.Char.Name))
 }
 
 func (d *GameDatabase) GetPlayerEntityByName(name string) Entity {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, e := range d.entities {
 		if e == nil {
 			continue
@@ -564,10 +578,7 @@ func (d *GameDatabase) ReadCharData(filename string) *CharData {
 }
 
 func (d *GameDatabase) SaveCharData(char_data *CharData, filename string) {
-	buf, err := yaml.Marshal(char_data)
-	ErrorCheck(err)
-	err = os.WriteFile(filename, buf, 0755)
-	ErrorCheck(err)
+	ErrorCheck(write_yaml(filename, char_data))
 }
 
 func (d *GameDatabase) AddEntity(entity Entity) {
@@ -600,8 +611,8 @@ func (d *GameDatabase) SpawnShip(ship Ship) Ship {
 }
 
 func (d *GameDatabase) GetShip(shipId uint) Ship {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, ship := range d.ships {
 		if ship.GetData().Id == shipId {
 			return ship
@@ -610,8 +621,8 @@ func (d *GameDatabase) GetShip(shipId uint) Ship {
 	return nil
 }
 func (d *GameDatabase) ShipNameAvailable(name string) bool {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, ship := range d.ships {
 		if strings.EqualFold(ship.GetData().Name, name) {
 			return false
@@ -621,8 +632,8 @@ func (d *GameDatabase) ShipNameAvailable(name string) bool {
 }
 func (d *GameDatabase) GetShipsInSystem(system string) []Ship {
 	ret := make([]Ship, 0)
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, ship := range d.ships {
 		s := ship.GetData()
 		if s.CurrentSystem == system {
@@ -634,8 +645,8 @@ func (d *GameDatabase) GetShipsInSystem(system string) []Ship {
 	return ret
 }
 func (d *GameDatabase) GetShipsInRoom(roomId uint) []Ship {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	ret := make([]Ship, 0)
 	for _, s := range d.ships {
 		if s.GetData().LocationId == roomId && !s.GetData().InSpace {
@@ -644,9 +655,60 @@ func (d *GameDatabase) GetShipsInRoom(roomId uint) []Ship {
 	}
 	return ret
 }
+
+// SystemStats is the head count for statsys and MSSP.
+type SystemStats struct {
+	Systems int
+	Areas   int
+	Rooms   int
+	Mobs    int
+	Players int
+	Ships   int
+}
+
+func (d *GameDatabase) Stats() SystemStats {
+	d.RLock()
+	defer d.RUnlock()
+	stats := SystemStats{
+		Systems: len(d.starsystems),
+		Areas:   len(d.areas),
+		Rooms:   len(d.rooms),
+		Ships:   len(d.ships),
+	}
+	for _, e := range d.entities {
+		if e == nil {
+			continue
+		}
+		if !e.IsPlayer() {
+			stats.Mobs++
+		} else {
+			stats.Players++
+		}
+	}
+	return stats
+}
+
+// A copy of the entity list, safe to range over while things die and spawn.
+func (d *GameDatabase) Entities() []Entity {
+	d.RLock()
+	defer d.RUnlock()
+	ret := make([]Entity, len(d.entities))
+	copy(ret, d.entities)
+	return ret
+}
+
+// Same for the connected clients.
+func (d *GameDatabase) Clients() []Client {
+	d.RLock()
+	defer d.RUnlock()
+	ret := make([]Client, len(d.clients))
+	copy(ret, d.clients)
+	return ret
+}
+
 func (d *GameDatabase) GetEntity(entity Entity) Entity {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, e := range d.entities {
 		if e == entity {
 			return e
@@ -655,8 +717,8 @@ func (d *GameDatabase) GetEntity(entity Entity) Entity {
 	return nil
 }
 func (d *GameDatabase) GetEntitiesInRoom(roomId uint, shipId uint) []Entity {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	ret := make([]Entity, 0)
 	for _, entity := range d.entities {
 		if entity == nil {
@@ -669,9 +731,20 @@ func (d *GameDatabase) GetEntitiesInRoom(roomId uint, shipId uint) []Entity {
 	return ret
 }
 
+// Every room in the areas, ships' rooms aren't in here.
+func (d *GameDatabase) Rooms() []*RoomData {
+	d.RLock()
+	defer d.RUnlock()
+	ret := make([]*RoomData, 0, len(d.rooms))
+	for _, room := range d.rooms {
+		ret = append(ret, room)
+	}
+	return ret
+}
+
 func (d *GameDatabase) GetRoom(roomId uint, shipId uint) *RoomData {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	if shipId > 0 {
 		for _, s := range d.ships {
 			if s.GetData().Id == shipId {
@@ -696,8 +769,8 @@ func (d *GameDatabase) GetRoom(roomId uint, shipId uint) *RoomData {
 }
 
 func (d *GameDatabase) GetNextRoomVnum(roomId uint, shipId uint) uint {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	if shipId > 0 {
 		for _, s := range d.ships {
 			if s.GetData().Id == shipId {
@@ -736,8 +809,8 @@ func (d *GameDatabase) GetNextRoomVnum(roomId uint, shipId uint) uint {
 	return 0
 }
 func (d *GameDatabase) GetNextItemVnum() uint {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for i := uint(1); i < (^uint(0)); i++ {
 		if _, ok := d.items[i]; !ok {
 			return i
@@ -746,8 +819,8 @@ func (d *GameDatabase) GetNextItemVnum() uint {
 	return 0
 }
 func (d *GameDatabase) GetNextMobVnum() uint {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for i := uint(1); i < (^uint(0)); i++ {
 		if _, ok := d.mobs[i]; !ok {
 			return i
@@ -756,8 +829,8 @@ func (d *GameDatabase) GetNextMobVnum() uint {
 	return 0
 }
 func (d *GameDatabase) GetNextShipVnum() uint {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for i := uint(1); i < (^uint(0)); i++ {
 		if _, ok := d.ship_prototypes[i]; !ok {
 			return i
@@ -767,8 +840,8 @@ func (d *GameDatabase) GetNextShipVnum() uint {
 }
 
 func (d *GameDatabase) GetItem(itemId uint) Item {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, i := range d.items {
 		if i == nil {
 			continue
@@ -781,8 +854,8 @@ func (d *GameDatabase) GetItem(itemId uint) Item {
 }
 
 func (d *GameDatabase) GetMob(mobId uint) Entity {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	if m, ok := d.mobs[mobId]; ok {
 		return m
 	}
@@ -790,8 +863,8 @@ func (d *GameDatabase) GetMob(mobId uint) Entity {
 }
 
 func (d *GameDatabase) GetEntityForClient(client Client) Entity {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	for _, e := range d.entities {
 		if e == nil {
 			continue
@@ -807,8 +880,8 @@ func (d *GameDatabase) GetEntityForClient(client Client) Entity {
 }
 
 func (d *GameDatabase) GetHelp(help string) []*HelpData {
-	d.Lock()
-	defer d.Unlock()
+	d.RLock()
+	defer d.RUnlock()
 	ret := []*HelpData{}
 	for _, h := range d.helps {
 		for _, keyword := range h.Keywords {
@@ -837,13 +910,13 @@ func (d *GameDatabase) SetRoom(id uint, room *RoomData) {
 
 func (d *GameDatabase) ResetAll() {
 	for area_name, area := range d.areas {
-		log.Printf("Resetting Area %s", area_name)
+		Log(LOG_DB).Debug("resetting area", "area", area_name)
 		area_reset(area)
 	}
 }
 
 func echo_all(msg string) {
-	for _, c := range DB().clients {
+	for _, c := range DB().Clients() {
 		c.Send(msg)
 	}
 }
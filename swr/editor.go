@@ -32,7 +32,15 @@ func run_editor(entity Entity, buffer string) string {
 }
 func editor(entity Entity, contents string) string {
 	player := entity.(*PlayerProfile)
-	client := player.Client.(*TCPClient)
+	client, ok := player.Client.(*TCPClient)
+	if !ok {
+		entity.Send("\r\n&RThe editor needs a telnet connection.&d\r\n")
+		return contents
+	}
+	// vim talks straight to the socket, it has no idea about MCCP
+	if client.compress_end() {
+		defer client.compress_start()
+	}
 	filename := sprintf("/tmp/%s", strings.ToLower(strings.ReplaceAll(entity.GetCharData().Name, " ", "")))
 	e := os.WriteFile(filename, []byte(contents), 0755)
 	ErrorCheck(e)
@@ -24,18 +24,6 @@ import (
 	"time"
 )
 
-var race_list = []string{
-	"Human", "Wookiee", "Twilek", "Rodian", "Hutt", "Mon Calamari", "Noghri",
-	"Gamorrean", "Jawa", "Adarian", "Ewok", "Verpine", "Defel", "Trandoshan",
-	"Hapan", "Quarren", "Shistavanen", "Falleen", "Ithorian", "Devaronian", "Gotal", "Droid",
-	"Firrerreo", "Barabel", "Bothan", "Togorian", "Dug", "Kubaz", "Selonian", "Gran", "Yevetha", "Gand",
-	"Duros", "Coynite", "Sullustan", "Protocol Droid", "Assassin Droid", "Gladiator Droid", "Astromech Droid",
-	"Interrogation Droid", "Sarlacc", "Saurin", "Snivvian", "Gand", "Gungan", "Weequay", "Bith",
-	"Ortolan", "Snit", "Cerean", "Ugnaught", "Taun Taun", "Bantha", "Tusken",
-	"Gherkin", "Zabrak", "Dewback", "Rancor", "Ronto",
-	"Monster",
-}
-
 const (
 	ENTITY_STAT_STR = iota // [0] Strength
 	ENTITY_STAT_INT        // [1] Intelligence
@@ -91,7 +79,7 @@ type CharData struct {
 	Keywords  []string             `yaml:"keywords,flow,omitempty"` // keywords to refer to this mob
 	Title     string               `yaml:"title,omitempty"`         // titles granted
 	Desc      string               `yaml:"desc"`                    // description of mob
-	Race      string               `yaml:"race,omitempty"`          // race name from [race_list]
+	Race      string               `yaml:"race,omitempty"`          // race name from [Races]
 	Gender    string               `yaml:"gender,omitempty"`        // single char gender, lowercase. m/f/n
 	Level     uint                 `yaml:"level,omitempty"`         // character level. 100 is max level.
 	XP        uint                 `yaml:"xp,omitempty"`            // character xp.
@@ -112,6 +100,7 @@ type CharData struct {
 	Flags     []string             `yaml:"flags,omitempty"`         // list of flags. See [entity_flags] for values.
 	AI        Brain                `yaml:"-"`                       // actual AI interface. instantiated upon spawn.
 	Attacker  Entity               `yaml:"-"`                       // who is this mob fighting?
+	Wait      int                  `yaml:"-"`                       // pulses until the next command can run. see [entity_wait]
 }
 
 // Returns true if the entity is a *PlayerProfile, false if just a *CharData mob.
@@ -163,24 +152,12 @@ func (c *CharData) MaxMv() int {
 	return c.Mv[1]
 }
 
-// Base weight of a person based on race (ignoring gender for sake of gender equality and body positivity ;)
+// Base weight of a person based on race, from data/races (ignoring gender for sake of gender equality and body positivity ;)
 func (c *CharData) base_weight() int {
-	switch c.Race {
-	case "Wookiee": // big guy eats steaks for sure
-		return 105
-	case "Hutt": // because there's no fatter
-		return 425
-	case "Ewok":
-	case "Jawa": // the tinyist of hero's
-		return 25
-	case "Droid": // metal weighs more than bone, facts.
-	case "Protocol Droid":
-		return 95
-	case "Assassin Droid": // so do guns...
-	case "Gladiator Droid":
-		return 245
-	}
-	return 75
+	if r := race_get(c.Race); r != nil {
+		return r.Weight
+	}
+	return RACE_WEIGHT_DEFAULT
 }
 
 // Calculates the current weight of the mob taking into account their inventory (equipment isn't factored, yet...)
@@ -365,18 +342,26 @@ func (c *CharData) GetCharData() *CharData {
 // [PlayerProfile] is an [Entity] that represents the player, not a mob. As such it has a few extra fields...
 // [Entity.IsPlayer] will return whether or not an [Entity] is a [*PlayerProfile] or just [*CharData]
 type PlayerProfile struct {
-	Char        CharData  `yaml:"char,inline"`
-	Email       string    `yaml:"email,omitempty" json:"email,omitempty"`
-	Password    string    `yaml:"password,omitempty" json:"-"`
-	Priv        int       `yaml:"priv,omitempty"`
-	LastSeen    time.Time `yaml:"last_seen,omitempty"`
-	Banned      bool      `yaml:"banned,omitempty"`
-	Frequency   string    `yaml:"freq"`
-	Kills       uint      `yaml:"kills"`
-	PKills      uint      `yaml:"pkills"`
-	Client      Client    `yaml:"-" gorm:"-"`
-	NeedPrompt  bool      `yaml:"-" gorm:"-"`
-	LastCommand string    `yaml:"-" gorm:"-"`
+	Char        CharData          `yaml:"char,inline"`
+	Account     uint              `yaml:"account,omitempty"`
+	Email       string            `yaml:"email,omitempty" json:"email,omitempty"`
+	Password    string            `yaml:"password,omitempty" json:"-"`
+	Priv        int               `yaml:"priv,omitempty"`
+	LastSeen    time.Time         `yaml:"last_seen,omitempty"`
+	Banned      bool              `yaml:"banned,omitempty"`
+	Frequency   string            `yaml:"freq"`
+	Kills       uint              `yaml:"kills"`
+	PKills      uint              `yaml:"pkills"`
+	Client      Client            `yaml:"-" gorm:"-"`
+	NeedPrompt  bool              `yaml:"-" gorm:"-"`
+	LastCommand string            `yaml:"-" gorm:"-"`
+	Aliases     map[string]string `yaml:"aliases,omitempty" gorm:"-"`
+	Wiznet      []string          `yaml:"wiznet,omitempty,flow" gorm:"-"` // wiznet channels they listen to
+	gmcp_room   uint              // last Room.Info we sent, so we only send on change
+	gmcp_ship   uint
+	linkdead    time.Time // when the connection dropped, zero while connected
+	linkbuf     []string  // what they missed while linkdead
+	pending     []string  // commands left over from an expanded line, see [command_expand]
 }
 
 // Is Entity a player?
@@ -389,6 +374,8 @@ func (p *PlayerProfile) Send(m string, any ...interface{}) {
 	if p.Client != nil {
 		p.Client.Sendf(m, any...)
 		p.NeedPrompt = true
+	} else if p.IsLinkdead() {
+		p.linkdead_send(fmt.Sprintf(m, any...))
 	}
 }
 
@@ -428,10 +415,18 @@ func (p *PlayerProfile) MaxMv() int {
 
 // Prompt the player, show's their stats, and readies for input next turn.
 func (p *PlayerProfile) Prompt() {
+	if p.Client == nil {
+		return
+	}
 	if p.NeedPrompt && p.Char.State != ENTITY_STATE_DEAD && !p.Client.IsEditing() {
 		prompt := player_prompt(p)
 		p.Send("%s\r\n", prompt)
 		p.NeedPrompt = false
+		gmcp_char_vitals(p)
+		if p.gmcp_room != p.Char.Room || p.gmcp_ship != p.Char.Ship {
+			p.gmcp_room, p.gmcp_ship = p.Char.Room, p.Char.Ship
+			gmcp_room_info(p)
+		}
 	}
 }
 
@@ -575,6 +570,16 @@ func entity_clone(entity Entity) Entity {
 	return c
 }
 
+// How wide the entity's screen is. Mobs don't have one, so they get the default.
+func entity_width(entity Entity) int {
+	if entity != nil && entity.IsPlayer() {
+		if client := entity.(*PlayerProfile).Client; client != nil {
+			return client.GetWidth()
+		}
+	}
+	return TERM_WIDTH_DEFAULT
+}
+
 // Builds a player prompt to send to the player using pretty ANSI colors and ASCII glyphs.
 func player_prompt(player *PlayerProfile) string {
 	mc := player.Client
@@ -605,10 +610,7 @@ func player_prompt(player *PlayerProfile) string {
 
 // Called every turn to process the hundreds of entities in the game. Processes state affects as well as health, movement, force regen.
 func processEntities() {
-	db := DB()
-
-	for i := range db.entities {
-		e := db.entities[i]
+	for _, e := range DB().Entities() {
 		if e == nil {
 			continue
 		}
@@ -786,20 +788,6 @@ func entity_unspeakable_state(entity Entity) bool {
 	return false
 }
 
-// Why can't they speak? (or see? (or breathe?)). Returns the reason an entity is in an unspeakable state.
-func entity_unspeakable_reason(entity Entity) string {
-	state := entity.GetCharData().State
-	switch state {
-	case ENTITY_STATE_DEAD:
-		return "dead"
-	case ENTITY_STATE_UNCONSCIOUS:
-		return "unconscious"
-	case ENTITY_STATE_SLEEPING:
-		return "sleeping"
-	}
-	return "none"
-}
-
 // Pick up an item off the ground or off a corpse (living or dead). Protects against picking up corpses or objects too heavy to lift.
 func entity_pickup_item(entity Entity, item Item) bool {
 	ch := entity.GetCharData()
@@ -18,16 +18,157 @@
 package swr
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
 )
 
+// Logs err, if there is one, with where it came from.
 func ErrorCheck(err error) {
 	if err != nil {
-		log.Printf("Error %+v", err)
+		_, file, line, _ := runtime.Caller(1)
+		Log(LOG_GAME).Error(fmt.Sprintf("%+v", err), "at", fmt.Sprintf("%s:%d", filepath.Base(file), line))
 	}
 }
 
 func Err(format string, any ...interface{}) error {
 	return fmt.Errorf(format, any...)
 }
+
+// What's wrong with a data file, and where. Fatal ones mean the thing in the
+// file can't be used at all, see [GameDatabase.Validate].
+type LoadError struct {
+	File  string
+	Line  int    // 0 when we don't know
+	Field string // like rooms[3].exits.north, empty if it's the whole file
+	Err   error
+	Fatal bool
+}
+
+func (e *LoadError) Error() string {
+	where := e.File
+	if e.Line > 0 {
+		where += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Field != "" {
+		where += ": " + e.Field
+	}
+	return where + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+var yaml_line_re = regexp.MustCompile(`line (\d+)`)
+
+// Reads a yaml file into out, migrating it from an older schema if it needs
+// it. Anything wrong comes back as a fatal [LoadError], with the line and
+// field when yaml tells us.
+func load_yaml(path string, out interface{}) error {
+	fp, err := os.ReadFile(path)
+	if err != nil {
+		return &LoadError{File: path, Err: err, Fatal: true}
+	}
+	return load_yaml_bytes(path, fp, out)
+}
+
+// load_yaml for yaml that isn't in a file of its own, a row in sqlite say.
+// path is whatever tells us where it came from.
+func load_yaml_bytes(path string, fp []byte, out interface{}) error {
+	node := new(yaml.Node)
+	if err := yaml.Unmarshal(fp, node); err != nil {
+		return load_error_yaml(path, err, nil)
+	}
+	if err := schema_migrate(path, fp, node); err != nil {
+		return err
+	}
+	if err := node.Decode(out); err != nil {
+		return load_error_yaml(path, err, node)
+	}
+	return nil
+}
+
+func load_error_yaml(path string, err error, node *yaml.Node) *LoadError {
+	ret := &LoadError{File: path, Err: err, Fatal: true}
+	var te *yaml.TypeError
+	if errors.As(err, &te) && len(te.Errors) > 0 {
+		// only the first one, the rest are usually more of the same
+		err = errors.New(te.Errors[0])
+		ret.Err = err
+	}
+	if m := yaml_line_re.FindStringSubmatch(err.Error()); m != nil {
+		ret.Line, _ = strconv.Atoi(m[1])
+	}
+	if node != nil && ret.Line > 0 {
+		ret.Field = yaml_field_at(node, ret.Line, "")
+	}
+	return ret
+}
+
+// Path to the value on a line, rooms[3].exits.north.
+func yaml_field_at(node *yaml.Node, line int, path string) string {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if f := yaml_field_at(n, line, path); f != "" {
+				return f
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field := key.Value
+			if path != "" {
+				field = path + "." + key.Value
+			}
+			if key.Line == line || value.Line == line && value.Kind == yaml.ScalarNode {
+				return field
+			}
+			if f := yaml_field_at(value, line, field); f != "" {
+				return f
+			}
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			field := fmt.Sprintf("%s[%d]", path, i)
+			if n.Line == line && n.Kind == yaml.ScalarNode {
+				return field
+			}
+			if f := yaml_field_at(n, line, field); f != "" {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// Deferred around anything that mustn't take the server down with it, a
+// command or a mudprog. Logs what blew up with a stack, true if something did.
+func recover_panic(r interface{}, what string, kv ...interface{}) bool {
+	if r == nil {
+		return false
+	}
+	stack := debug.Stack()
+	if gp, ok := r.(game_panic); ok {
+		// it blew up on the game loop, that stack is the interesting one
+		r, stack = gp.value, gp.stack
+	}
+	kv = append(kv, "panic", fmt.Sprint(r), "stack", string(stack))
+	Log(LOG_GAME).Error(what+" panicked", kv...)
+	return true
+}
+
+// A panic caught on the game loop, handed back to whoever was waiting in
+// game_sync.
+type game_panic struct {
+	value interface{}
+	stack []byte
+}
@@ -19,7 +19,6 @@ package swr
 
 import (
 	"fmt"
-	"log"
 	"strings"
 )
 
@@ -99,8 +98,7 @@ func do_fight(entity Entity, args ...string) {
 }
 
 func processCombat() {
-	db := DB()
-	el := db.entities
+	el := DB().Entities()
 	for _, e := range el {
 		if e != nil {
 			if e.IsFighting() {
@@ -189,7 +187,7 @@ func do_combat(attacker Entity, defender Entity) {
 		xp_base = 275
 		make_corpse(attacker)
 		entity_award_kill(defender, attacker)
-		log.Printf("Entity %s [%d] has been killed by %s.", ach.Name, ach.Id, dch.Name)
+		Log(LOG_COMBAT).Wiz(WIZ_DEATHS).Info(sprintf("%s has been killed by %s", ach.Name, dch.Name), "id", ach.Id, "room", ach.Room)
 		entity_add_xp(defender, xp_base)
 		entity_lose_xp(attacker, xp_base)
 		return
@@ -212,7 +210,7 @@ func do_combat(attacker Entity, defender Entity) {
 		xp_base = 275
 		make_corpse(defender)
 		entity_award_kill(attacker, defender)
-		log.Printf("Entity %s [%d] has been killed by %s.", dch.Name, dch.Id, ach.Name)
+		Log(LOG_COMBAT).Wiz(WIZ_DEATHS).Info(sprintf("%s has been killed by %s", dch.Name, ach.Name), "id", dch.Id, "room", dch.Room)
 		entity_lose_xp(defender, xp_base)
 		entity_add_xp(attacker, xp_base)
 		return
@@ -0,0 +1,223 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// GMCPSupports is the list of packages the client told us it wants through
+// Core.Supports.Set/Add/Remove. Until it says anything we send everything.
+type GMCPSupports struct {
+	m        *sync.Mutex
+	told     bool
+	packages map[string]int
+}
+
+func NewGMCPSupports() *GMCPSupports {
+	return &GMCPSupports{
+		m:        &sync.Mutex{},
+		packages: make(map[string]int),
+	}
+}
+
+// Wants checks "Char.Vitals" against "Char 1", "Char.Vitals 1" and friends.
+func (s *GMCPSupports) Wants(pkg string) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.told {
+		return true
+	}
+	parts := strings.Split(strings.ToLower(pkg), ".")
+	for i := len(parts); i > 0; i-- {
+		if _, ok := s.packages[strings.Join(parts[:i], ".")]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *GMCPSupports) set(list []string, clear bool, remove bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.told = true
+	if clear {
+		s.packages = make(map[string]int)
+	}
+	for _, p := range list {
+		fields := strings.Fields(p)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if remove {
+			delete(s.packages, name)
+			continue
+		}
+		s.packages[name] = 1
+	}
+}
+
+// Hook GMCP into a telnet session. Messages we care about from the client are
+// the Core.* ones, anything else is ignored for now.
+func gmcp_telnet_init(t *Telnet, supports *GMCPSupports) {
+	t.Support(NET_GMCP, true, false)
+	t.Handle(NET_GMCP, &TelnetHandler{
+		OnSub: func(data []byte) {
+			pkg, payload := gmcp_split(data)
+			switch strings.ToLower(pkg) {
+			case "core.supports.set":
+				supports.set(gmcp_list(payload), true, false)
+			case "core.supports.add":
+				supports.set(gmcp_list(payload), false, false)
+			case "core.supports.remove":
+				supports.set(gmcp_list(payload), false, true)
+			}
+		},
+	})
+	t.EnableLocal(NET_GMCP)
+}
+
+// Encode a GMCP message, "Package.Name <json>".
+func gmcp_encode(pkg string, data interface{}) []byte {
+	if data == nil {
+		return []byte(pkg)
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		ErrorCheck(err)
+		return []byte(pkg)
+	}
+	return []byte(pkg + " " + string(payload))
+}
+
+func gmcp_split(data []byte) (string, string) {
+	msg := strings.TrimSpace(string(data))
+	if i := strings.IndexAny(msg, " \t"); i > 0 {
+		return msg[:i], strings.TrimSpace(msg[i+1:])
+	}
+	return msg, ""
+}
+
+func gmcp_list(payload string) []string {
+	list := make([]string, 0)
+	if err := json.Unmarshal([]byte(payload), &list); err != nil {
+		return make([]string, 0)
+	}
+	return list
+}
+
+func gmcp_client(entity Entity) Client {
+	if entity == nil || !entity.IsPlayer() {
+		return nil
+	}
+	return entity.(*PlayerProfile).Client
+}
+
+// Char.Vitals {"hp":..,"maxhp":..,"mv":..,"maxmv":..,"mp":..,"maxmp":..}
+func gmcp_char_vitals(entity Entity) {
+	client := gmcp_client(entity)
+	if client == nil {
+		return
+	}
+	ch := entity.GetCharData()
+	vitals := map[string]int{}
+	if len(ch.Hp) > 1 {
+		vitals["hp"], vitals["maxhp"] = ch.Hp[0], ch.Hp[1]
+	}
+	if len(ch.Mv) > 1 {
+		vitals["mv"], vitals["maxmv"] = ch.Mv[0], ch.Mv[1]
+	}
+	if len(ch.Mp) > 1 {
+		vitals["mp"], vitals["maxmp"] = ch.Mp[0], ch.Mp[1]
+	}
+	client.SendGMCP("Char.Vitals", vitals)
+}
+
+// Room.Info {"num":..,"name":..,"area":..,"exits":{"n":..}}
+func gmcp_room_info(entity Entity) {
+	client := gmcp_client(entity)
+	if client == nil {
+		return
+	}
+	ch := entity.GetCharData()
+	room := DB().GetRoom(ch.Room, ch.Ship)
+	if room == nil {
+		return
+	}
+	area := ""
+	if room.Area != nil {
+		area = room.Area.Name
+	} else if ch.Ship > 0 {
+		if ship := DB().GetShip(ch.Ship); ship != nil {
+			area = ship.GetData().Name
+		}
+	}
+	exits := make(map[string]uint)
+	for dir, to := range room.Exits {
+		exits[gmcp_direction(dir)] = to
+	}
+	client.SendGMCP("Room.Info", map[string]interface{}{
+		"num":   room.Id,
+		"name":  Color().Decolorize(room.Name),
+		"area":  area,
+		"exits": exits,
+	})
+}
+
+// Comm.Channel.Text {"channel":..,"talker":..,"text":..}
+func gmcp_comm_channel(entity Entity, channel string, talker string, text string) {
+	client := gmcp_client(entity)
+	if client == nil {
+		return
+	}
+	client.SendGMCP("Comm.Channel.Text", map[string]string{
+		"channel": channel,
+		"talker":  talker,
+		"text":    Color().Decolorize(text),
+	})
+}
+
+// Mudlet's mapper (and everybody copying IRE) wants short exit names.
+func gmcp_direction(dir string) string {
+	switch dir {
+	case "north":
+		return "n"
+	case "northeast":
+		return "ne"
+	case "east":
+		return "e"
+	case "southeast":
+		return "se"
+	case "south":
+		return "s"
+	case "southwest":
+		return "sw"
+	case "west":
+		return "w"
+	case "northwest":
+		return "nw"
+	case "up":
+		return "u"
+	case "down":
+		return "d"
+	}
+	return dir
+}
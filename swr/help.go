@@ -29,14 +29,14 @@ func do_help(entity Entity, args ...string) {
 	if len(args) > 0 {
 		help := db.GetHelp(strings.Join(args, " "))
 		if len(help) > 0 {
-			player.Send("\r\n&W%s&d\r\n", MakeTitle(help[0].Name, ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER))
+			player.Send("\r\n&W%s&d\r\n", MakeTitle(help[0].Name, ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
 			player.Send("&YKeywords: &g%v&d\r\n", help[0].Keywords)
 			player.Send("&w%s&d\r\n", help[0].Desc)
 		} else {
 			player.Send("\r\n&RNo help file for keyword &Y%s\r\n", strings.Join(args, " "))
 		}
 	} else {
-		player.Send("\r\n&W%s&d\r\n", MakeTitle("Help", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER))
+		player.Send("\r\n&W%s&d\r\n", MakeTitle("Help", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
 		keys := []string{} // slice to keep track of all of the keywords of all the help files.
 		for i := range db.helps {
 			if db.helps[i].Level <= uint(player.Priv) { // if the help file level is less than or equal to our priv (access level)
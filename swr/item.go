@@ -19,6 +19,8 @@ package swr
 
 import (
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -108,6 +110,39 @@ func (i *ItemData) String() string {
 	return i.Name
 }
 
+// yaml can't make an [Item] out of thin air, so what's in a container is read
+// as [ItemData].
+func (i *ItemData) UnmarshalYAML(node *yaml.Node) error {
+	type plain ItemData
+	var contains *yaml.Node
+	if node.Kind == yaml.MappingNode {
+		rest := *node
+		rest.Content = make([]*yaml.Node, 0, len(node.Content))
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == "contains" {
+				contains = node.Content[j+1]
+				continue
+			}
+			rest.Content = append(rest.Content, node.Content[j], node.Content[j+1])
+		}
+		node = &rest
+	}
+	if err := node.Decode((*plain)(i)); err != nil {
+		return err
+	}
+	i.Items = nil
+	if contains != nil {
+		items := make([]*ItemData, 0)
+		if err := contains.Decode(&items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			i.Items = append(i.Items, item)
+		}
+	}
+	return nil
+}
+
 func (i *ItemData) GetData() *ItemData {
 	return i
 }
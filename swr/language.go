@@ -47,10 +47,8 @@ func LanguageLoad() {
 			ErrorCheck(err)
 			l := new(Language)
 			yaml.Unmarshal(fp, l)
-			for _, race := range race_list {
-				if strings.EqualFold(race, l.Race) {
-					Languages = append(Languages, *l)
-				}
+			if race_get(l.Race) != nil {
+				Languages = append(Languages, *l)
 			}
 
 		}
@@ -119,7 +117,7 @@ func language_spoken(speaker *CharData, listener *CharData, words string) string
 }
 
 func language_decay() {
-	for _, entity := range DB().entities {
+	for _, entity := range DB().Entities() {
 		if entity == nil {
 			continue
 		}
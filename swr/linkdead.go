@@ -0,0 +1,120 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"fmt"
+	"time"
+)
+
+// When a connection drops the player stays in the world without a client, they
+// are linkdead. Whatever the game tells them meanwhile is kept, and handed over
+// when they log back in. If they don't come back in time they are saved and
+// pulled out of the world.
+
+const (
+	LINKDEAD_GRACE  = 5 * time.Minute // unless config.yml says otherwise
+	LINKDEAD_BUFFER = 200             // messages kept for a linkdead player
+)
+
+func linkdead_grace() time.Duration {
+	if Config().Linkdead > 0 {
+		return time.Duration(Config().Linkdead) * time.Second
+	}
+	return LINKDEAD_GRACE
+}
+
+func (p *PlayerProfile) IsLinkdead() bool {
+	return p.Client == nil && !p.linkdead.IsZero()
+}
+
+// For who and room listings.
+func linkdead_tag(entity Entity) string {
+	if entity != nil && entity.IsPlayer() && entity.(*PlayerProfile).IsLinkdead() {
+		return " &x(linkdead)&d"
+	}
+	return ""
+}
+
+// Called on the game loop once the player's connection is gone.
+func player_linkdead(player *PlayerProfile) {
+	Log(LOG_NET).Wiz(WIZ_LOGINS).Info(sprintf("%s has gone linkdead", player.Char.Name))
+	player.Client = nil
+	player.linkdead = time.Now()
+	player.linkbuf = make([]string, 0)
+	player.ClearPending()
+	if room := DB().GetRoom(player.RoomId(), player.ShipId()); room != nil {
+		for _, e := range room.GetEntities() {
+			if e != player {
+				e.Send("\r\n&P%s&d has lost their link.\r\n", player.Char.Name)
+			}
+		}
+	}
+}
+
+func (p *PlayerProfile) linkdead_send(str string) {
+	p.linkbuf = append(p.linkbuf, str)
+	if len(p.linkbuf) > LINKDEAD_BUFFER {
+		p.linkbuf = p.linkbuf[len(p.linkbuf)-LINKDEAD_BUFFER:]
+	}
+}
+
+// Puts a new client into a linkdead body and replays what they missed. Game
+// loop only.
+func player_reconnect(player *PlayerProfile, client Client) {
+	Log(LOG_NET).Wiz(WIZ_LOGINS).Info(sprintf("%s has reconnected", player.Char.Name), "after", time.Since(player.linkdead).Round(time.Second), "addr", client.GetAddr())
+	missed := player.linkbuf
+	player.linkdead = time.Time{}
+	player.linkbuf = nil
+	player.Client = client
+	player.LastSeen = time.Now()
+	player.gmcp_room, player.gmcp_ship = 0, 0 // the new client wants a Room.Info
+	if len(missed) > 0 {
+		client.Send("\r\n&YWhile you were away...&d\r\n")
+		for _, str := range missed {
+			client.Send(str)
+		}
+	}
+	if room := DB().GetRoom(player.RoomId(), player.ShipId()); room != nil {
+		for _, e := range room.GetEntities() {
+			if e != player {
+				e.Send("\r\n&P%s&d has reconnected.\r\n", player.Char.Name)
+			}
+		}
+	}
+}
+
+// Once a second on the game loop, out go the ones that never came back.
+func processLinkdead() {
+	grace := linkdead_grace()
+	for _, e := range DB().Entities() {
+		if e == nil || !e.IsPlayer() {
+			continue
+		}
+		player := e.(*PlayerProfile)
+		if !player.IsLinkdead() || time.Since(player.linkdead) < grace {
+			continue
+		}
+		Log(LOG_NET).Wiz(WIZ_LOGINS).Info(sprintf("%s was linkdead too long, extracting", player.Char.Name))
+		DB().SavePlayerData(player)
+		DB().RemoveEntity(player)
+		if room := DB().GetRoom(player.RoomId(), player.ShipId()); room != nil {
+			room.SendToRoom(fmt.Sprintf("\r\n&P%s&d fades away.\r\n", player.Char.Name))
+		}
+	}
+}
@@ -0,0 +1,309 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leveled, structured logging. Every line has a level, the subsystem it came
+// from and a message, plus whatever key/value pairs the caller hands over:
+//
+//	Log(LOG_NET).Info("connection accepted", "addr", addr)
+//
+// Lines go to the console and to data/logs/swr.log, which is rotated as it
+// grows. Anything still using log.Printf ends up in here too, as game/info.
+// A line can also be put out on a wiznet channel for immortals in the game,
+// see [Logger.Wiz].
+
+type LogLevel int
+
+const (
+	LOG_DEBUG LogLevel = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+var log_level_names = []string{"debug", "info", "warn", "error"}
+
+func (l LogLevel) String() string {
+	if l < LOG_DEBUG || l > LOG_ERROR {
+		return "unknown"
+	}
+	return log_level_names[l]
+}
+
+func log_level(name string) (LogLevel, bool) {
+	for i, n := range log_level_names {
+		if strings.EqualFold(n, name) {
+			return LogLevel(i), true
+		}
+	}
+	return LOG_INFO, false
+}
+
+// Subsystems
+const (
+	LOG_GAME   = "game" // everything else, and whatever still uses log.Printf
+	LOG_NET    = "net"
+	LOG_AUTH   = "auth"
+	LOG_COMBAT = "combat"
+	LOG_PROGS  = "progs"
+	LOG_DB     = "db"
+)
+
+const (
+	LOG_FILE     = "swr.log"
+	LOG_MAX_SIZE = 10 // MB
+	LOG_KEEP     = 5
+)
+
+type LogConfig struct {
+	Level      string            `yaml:"level,omitempty"`      // debug, info (the default), warn or error
+	Format     string            `yaml:"format,omitempty"`     // text (the default) or json
+	Dir        string            `yaml:"dir,omitempty"`        // defaults to data/logs
+	MaxSize    int               `yaml:"max_size,omitempty"`   // MB before the file is rotated
+	Keep       int               `yaml:"keep,omitempty"`       // rotated files kept around
+	Subsystems map[string]string `yaml:"subsystems,omitempty"` // levels per subsystem, like net: debug
+}
+
+// Where the lines end up. Until LogInit runs it's just the console.
+type log_sink struct {
+	m       sync.Mutex
+	level   LogLevel
+	levels  map[string]LogLevel
+	json    bool
+	console io.Writer
+	file    *os.File
+	path    string
+	size    int64
+	max     int64
+	keep    int
+}
+
+var _log_sink = &log_sink{
+	level:   LOG_INFO,
+	levels:  make(map[string]LogLevel),
+	console: os.Stderr,
+}
+
+// Sets up logging from the log section of config.yml and takes over the
+// standard logger.
+func LogInit() {
+	conf := Config().Log
+	s := _log_sink
+	s.m.Lock()
+	if l, ok := log_level(conf.Level); ok {
+		s.level = l
+	}
+	for sys, name := range conf.Subsystems {
+		if l, ok := log_level(name); ok {
+			s.levels[sys] = l
+		}
+	}
+	s.json = strings.EqualFold(conf.Format, "json")
+	dir := conf.Dir
+	if dir == "" {
+		dir = "data/logs"
+	}
+	s.max = int64(conf.MaxSize) * 1024 * 1024
+	if s.max <= 0 {
+		s.max = LOG_MAX_SIZE * 1024 * 1024
+	}
+	s.keep = conf.Keep
+	if s.keep <= 0 {
+		s.keep = LOG_KEEP
+	}
+	s.path = filepath.Join(dir, LOG_FILE)
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		err = s.open()
+	}
+	s.m.Unlock()
+	if err != nil {
+		Log(LOG_GAME).Error("can't open the log file, logging to the console only", "path", s.path, "err", err)
+	}
+	log.SetFlags(0)
+	log.SetOutput(std_log_writer{})
+}
+
+func (s *log_sink) open() error {
+	fp, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	s.file = fp
+	s.size = st.Size()
+	return nil
+}
+
+// swr.log becomes swr.log.1, swr.log.1 becomes swr.log.2 and so on, the oldest
+// falls off the end.
+func (s *log_sink) rotate() {
+	s.file.Close()
+	s.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.keep))
+	for i := s.keep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	os.Rename(s.path, s.path+".1")
+	if err := s.open(); err != nil {
+		fmt.Fprintf(s.console, "Error reopening log file %s: %v\n", s.path, err)
+	}
+}
+
+func (s *log_sink) enabled(sys string, level LogLevel) bool {
+	min, ok := s.levels[sys]
+	if !ok {
+		min = s.level
+	}
+	return level >= min
+}
+
+func (s *log_sink) write(line []byte) {
+	s.console.Write(line)
+	if s.file == nil {
+		return
+	}
+	if s.size+int64(len(line)) > s.max {
+		s.rotate()
+		if s.file == nil {
+			return
+		}
+	}
+	n, _ := s.file.Write(line)
+	s.size += int64(n)
+}
+
+type Logger struct {
+	sys string
+	wiz string
+}
+
+// A logger for the subsystem, LOG_NET, LOG_AUTH...
+func Log(sys string) Logger {
+	return Logger{sys: sys}
+}
+
+// Also put the line out on a wiznet channel.
+func (l Logger) Wiz(channel string) Logger {
+	l.wiz = channel
+	return l
+}
+
+func (l Logger) Debug(msg string, kv ...interface{}) {
+	l.write(LOG_DEBUG, msg, kv)
+}
+
+func (l Logger) Info(msg string, kv ...interface{}) {
+	l.write(LOG_INFO, msg, kv)
+}
+
+func (l Logger) Warn(msg string, kv ...interface{}) {
+	l.write(LOG_WARN, msg, kv)
+}
+
+func (l Logger) Error(msg string, kv ...interface{}) {
+	l.write(LOG_ERROR, msg, kv)
+}
+
+func (l Logger) write(level LogLevel, msg string, kv []interface{}) {
+	if len(kv)%2 == 1 {
+		kv = append(kv, "?")
+	}
+	fields := log_fields_text(kv)
+	if l.wiz != "" {
+		wiznet(l.wiz, level, strings.TrimSpace(msg+" "+fields))
+	} else if level == LOG_ERROR {
+		wiznet(WIZ_ERRORS, level, strings.TrimSpace(fmt.Sprintf("[%s] %s %s", l.sys, msg, fields)))
+	}
+	s := _log_sink
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.enabled(l.sys, level) {
+		return
+	}
+	now := time.Now()
+	var line []byte
+	if s.json {
+		line = log_line_json(now, level, l.sys, msg, kv)
+	} else {
+		line = []byte(strings.TrimRight(fmt.Sprintf("%s %-5s %-6s %s %s", now.Format("2006/01/02 15:04:05"), strings.ToUpper(level.String()), l.sys, msg, fields), " ") + "\n")
+	}
+	s.write(line)
+}
+
+// key=value key2="with spaces"
+func log_fields_text(kv []interface{}) string {
+	buf := make([]string, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := fmt.Sprint(kv[i+1])
+		if v == "" || strings.ContainsAny(v, " =\"\t\r\n") {
+			v = strconv.Quote(v)
+		}
+		buf = append(buf, fmt.Sprintf("%v=%s", kv[i], v))
+	}
+	return strings.Join(buf, " ")
+}
+
+// One object per line, fields in the order they were given.
+func log_line_json(now time.Time, level LogLevel, sys string, msg string, kv []interface{}) []byte {
+	buf := make([]string, 0, 4+len(kv)/2)
+	add := func(k string, v interface{}) {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(v)
+		if err != nil {
+			vb, _ = json.Marshal(fmt.Sprint(v))
+		}
+		buf = append(buf, string(kb)+":"+string(vb))
+	}
+	add("time", now.Format(time.RFC3339))
+	add("level", level.String())
+	add("sys", sys)
+	add("msg", msg)
+	for i := 0; i+1 < len(kv); i += 2 {
+		add(fmt.Sprint(kv[i]), kv[i+1])
+	}
+	return []byte("{" + strings.Join(buf, ",") + "}\n")
+}
+
+// log.Printf and friends, once LogInit has taken over the standard logger.
+type std_log_writer struct{}
+
+func (std_log_writer) Write(p []byte) (int, error) {
+	Log(LOG_GAME).Info(strings.TrimRight(string(p), "\r\n"))
+	return len(p), nil
+}
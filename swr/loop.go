@@ -0,0 +1,209 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The game runs in pulses. Every pulse each player gets to run a couple of
+// the commands they've typed (unless they're still lagged from the last
+// one), and once a second combat, healing, AI and idle checks happen. All of
+// it on one goroutine, so the game never races with itself.
+const (
+	PULSE            = 250 * time.Millisecond
+	PULSE_PER_SECOND = int(time.Second / PULSE)
+	PULSE_COMMANDS   = 2  // commands per player per pulse
+	INPUT_QUEUE_MAX  = 32 // lines a client can have waiting before we drop them
+)
+
+// InputQueue holds what a client typed until the game loop gets to it. The
+// connection's goroutine pushes, the game loop pops.
+type InputQueue struct {
+	m     *sync.Mutex
+	lines []string
+}
+
+func NewInputQueue() *InputQueue {
+	return &InputQueue{
+		m:     &sync.Mutex{},
+		lines: make([]string, 0, INPUT_QUEUE_MAX),
+	}
+}
+
+// Push returns false (and drops the line) if the queue is full.
+func (q *InputQueue) Push(line string) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+	if len(q.lines) >= INPUT_QUEUE_MAX {
+		return false
+	}
+	q.lines = append(q.lines, line)
+	return true
+}
+
+func (q *InputQueue) Pop() (string, bool) {
+	q.m.Lock()
+	defer q.m.Unlock()
+	if len(q.lines) == 0 {
+		return "", false
+	}
+	line := q.lines[0]
+	q.lines = q.lines[1:]
+	return line, true
+}
+
+func (q *InputQueue) Len() int {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return len(q.lines)
+}
+
+func (q *InputQueue) Clear() {
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.lines = make([]string, 0, INPUT_QUEUE_MAX)
+}
+
+// Work handed to the game loop from other goroutines, see [game_sync].
+var GameActions chan func() = make(chan func(), 256)
+
+var game_running int32
+
+// The world belongs to the game loop. Anything on another goroutine (a
+// connection logging in, a mudprog, the backup) that wants to touch it wraps
+// that bit in game_sync, which runs it on the next pulse and waits. Never call
+// it from the game loop itself (commands, combat, resets), it would wait on
+// itself forever. Before the loop starts (boot) it just runs fn.
+func game_sync(fn func()) {
+	if atomic.LoadInt32(&game_running) == 0 {
+		fn()
+		return
+	}
+	done := make(chan bool)
+	var p interface{}
+	GameActions <- func() {
+		defer close(done)
+		defer func() {
+			// not the game loop's problem, the caller gets it back below
+			if r := recover(); r != nil {
+				p = game_panic{value: r, stack: debug.Stack()}
+			}
+		}()
+		fn()
+	}
+	<-done
+	if p != nil {
+		panic(p)
+	}
+}
+
+// Queue up a command from the server itself, like the "look" when you enter
+// the game. Runs on the next pulse.
+func queue_command(entity Entity, input string) {
+	select {
+	case ServerQueue <- MudClientCommand{Entity: entity, Command: input}:
+	default:
+		Log(LOG_GAME).Warn("server queue is full, dropped a command", "entity", entity.GetCharData().Name, "input", input)
+	}
+}
+
+// Lag an entity for a number of pulses, nothing they type runs until it's up.
+// Players only, mobs act on the AI's clock and nothing would count it down.
+func entity_wait(entity Entity, pulses int) {
+	if !entity.IsPlayer() {
+		return
+	}
+	ch := entity.GetCharData()
+	if pulses > ch.Wait {
+		ch.Wait = pulses
+	}
+}
+
+func processGameLoop() {
+	ticker := time.NewTicker(PULSE)
+	defer ticker.Stop()
+	atomic.StoreInt32(&game_running, 1)
+	defer atomic.StoreInt32(&game_running, 0)
+	pulse := 0
+	for {
+		if !ServerRunning {
+			break
+		}
+		<-ticker.C
+		pulse++
+		processInput()
+		if pulse%PULSE_PER_SECOND == 0 {
+			Scheduler().tick(time.Now().UTC())
+			processIdleClients()
+			processLinkdead()
+			processCombat()
+			processEntities()
+			updateMinerDifficulty()
+		}
+		processOutput()
+	}
+	log.Printf("Game loop has exited!\n")
+}
+
+// Whatever the pulse had to say goes out now.
+func processOutput() {
+	for _, c := range DB().Clients() {
+		c.Flush()
+	}
+}
+
+func processInput() {
+	for n := len(GameActions); n > 0; n-- {
+		fn := <-GameActions
+		fn()
+	}
+	processWiznet()
+	// only what was queued before this pulse, anything queued now waits
+	for n := len(ServerQueue); n > 0; n-- {
+		cmd := <-ServerQueue
+		do_command(cmd.Entity, cmd.Command)
+	}
+	for _, e := range DB().Entities() {
+		if e == nil || !e.IsPlayer() {
+			continue
+		}
+		player := e.(*PlayerProfile)
+		if player.Client == nil {
+			continue
+		}
+		ch := player.GetCharData()
+		for i := 0; i < PULSE_COMMANDS; i++ {
+			if ch.Wait > 0 {
+				break
+			}
+			input, ok := player.NextCommand()
+			if !ok {
+				break
+			}
+			do_command(player, input)
+		}
+		if ch.Wait > 0 {
+			ch.Wait--
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Hundreds of players doing what players do, all at once, to shake out
+// anything that touches the world off the game loop. Run it with -race.
+
+const LOAD_TEST_CLIENTS = 300
+
+var load_test_commands = []string{
+	"north", "south", "east", "west", "up", "down",
+	"look", "who", "score", "inventory",
+	"say hello there", "shout anyone around?", "ooc testing",
+}
+
+// A client that reads from a script instead of a socket.
+type test_client struct {
+	id      string
+	lines   chan string
+	done    chan bool
+	input   *InputQueue
+	closed  int32
+	idle    int32
+	editing int32
+	sent    int64 // bytes the game sent us
+}
+
+func new_test_client(id string, script []string) *test_client {
+	c := &test_client{
+		id:    id,
+		lines: make(chan string, len(script)),
+		done:  make(chan bool),
+		input: NewInputQueue(),
+	}
+	for _, line := range script {
+		c.lines <- line
+	}
+	return c
+}
+
+func (c *test_client) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+func (c *test_client) Raw(buffer []byte) {
+	atomic.AddInt64(&c.sent, int64(len(buffer)))
+}
+func (c *test_client) Send(str string) {
+	c.Raw([]byte(Color().Render(str, c.GetCaps())))
+}
+func (c *test_client) Sendf(format string, any ...interface{}) {
+	c.Send(fmt.Sprintf(format, any...))
+}
+
+// The next line of the script, paced like somebody typing, then nothing
+// until the game hangs up on us.
+func (c *test_client) Read() string {
+	select {
+	case line := <-c.lines:
+		time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
+		return line
+	case <-c.done:
+		return ""
+	}
+}
+func (c *test_client) ReadRaw(b []byte) (int, error) {
+	return 0, nil
+}
+func (c *test_client) BufferEditor(buf *string) {}
+func (c *test_client) Close() {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		close(c.done)
+	}
+}
+func (c *test_client) GetId() string {
+	return c.id
+}
+func (c *test_client) SetEditing(editing bool) {
+	atomic.StoreInt32(&c.editing, bool_int32(editing))
+}
+func (c *test_client) IsEditing() bool {
+	return atomic.LoadInt32(&c.editing) == 1
+}
+func (c *test_client) IdleInc() {
+	atomic.AddInt32(&c.idle, 1)
+}
+func (c *test_client) GetIdle() int {
+	return int(atomic.LoadInt32(&c.idle))
+}
+func (c *test_client) IdleReset() {
+	atomic.StoreInt32(&c.idle, 0)
+}
+func (c *test_client) SendQueue()                            {}
+func (c *test_client) Flush()                                {}
+func (c *test_client) ClearQueue()                           {}
+func (c *test_client) Telnet() *Telnet                       { return nil }
+func (c *test_client) SendGMCP(pkg string, data interface{}) {}
+func (c *test_client) GetWidth() int                         { return TERM_WIDTH_DEFAULT }
+func (c *test_client) GetCaps() TermCaps                     { return TERM_CAPS_DEFAULT }
+func (c *test_client) Input() *InputQueue                    { return c.input }
+func (c *test_client) GetAddr() string                       { return "127.0.0.1" }
+
+var test_boot_once sync.Once
+
+// What Main does, short of listening.
+func test_boot() {
+	test_boot_once.Do(func() {
+		LogInit()
+		DB().Load()
+		DB().ResetAll()
+		DB().RestoreWorld()
+		CommandsLoad()
+		LanguageLoad()
+	})
+}
+
+// The end of character creation, without the questions.
+func test_login(name string) func(client Client) {
+	return func(client Client) {
+		races := race_playable()
+		race := races[rand.Intn(len(races))]
+		player := new(PlayerProfile)
+		player.Char.Id = gen_player_char_id()
+		player.Char.Name = name
+		player.Char.Title = name + " the " + race.Name
+		player.Char.Level = 1
+		player.Char.Stats = roll_stats()
+		player.Char.Hp = []int{50, 50}
+		player.Char.Mp = []int{0, 0}
+		player.Char.Mv = []int{50, 50}
+		player.Char.Equipment = make(map[string]*ItemData)
+		player.Char.Inventory = make([]*ItemData, 0)
+		player.Char.Keywords = []string{name, race.Name}
+		player.Char.Brain = "client"
+		race.Init(&player.Char)
+		player.Frequency = tune_random_frequency()
+		player.Client = client
+		game_sync(func() {
+			DB().AddEntity(player)
+			queue_command(player, "look")
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("takes a while")
+	}
+	test_boot()
+	ServerRunning = true
+	loop := make(chan bool)
+	go func() {
+		processGameLoop()
+		close(loop)
+	}()
+	defer func() {
+		// on the loop, it's the one reading it
+		game_sync(func() { ServerRunning = false })
+		<-loop
+	}()
+
+	clients := make([]*test_client, LOAD_TEST_CLIENTS)
+	var wg sync.WaitGroup
+	for i := range clients {
+		name := fmt.Sprintf("Loadtest%03d", i)
+		script := make([]string, 0)
+		for n := 0; n < 10+rand.Intn(10); n++ {
+			script = append(script, load_test_commands[rand.Intn(len(load_test_commands))])
+		}
+		script = append(script, "quit")
+		clients[i] = new_test_client(name, script)
+		wg.Add(1)
+		go func(c *test_client, name string) {
+			defer wg.Done()
+			client_session(c, test_login(name))
+		}(clients[i], name)
+	}
+	// and the things that touch the world from elsewhere while they play
+	stop := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(2 * time.Second):
+				game_sync(func() { DB().Save() })
+				echo_all("\r\n&YThe ground shakes.&d\r\n")
+			}
+		}
+	}()
+
+	finished := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Minute):
+		t.Fatal("clients still connected after 5 minutes")
+	}
+	close(stop)
+
+	for _, c := range clients {
+		if atomic.LoadInt64(&c.sent) == 0 {
+			t.Errorf("%s never heard from the game", c.id)
+		}
+	}
+	for _, e := range DB().Entities() {
+		if e != nil && e.IsPlayer() {
+			t.Errorf("%s is still in the game after quitting", e.GetCharData().Name)
+		}
+	}
+	if n := len(DB().Clients()); n > 0 {
+		t.Errorf("%d clients left over", n)
+	}
+}
@@ -0,0 +1,106 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer sends email to players. Which one we get depends on the mail section
+// of config.yml, an smtp server if there is one, otherwise mail is left in the
+// outbox of the storage, as files in a directory with yaml storage (handy on
+// a dev box, and for tests).
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+type MailConfig struct {
+	From     string `yaml:"from,omitempty"`
+	SMTP     string `yaml:"smtp,omitempty"` // host:port, empty to leave mail in the outbox instead
+	User     string `yaml:"user,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	Dir      string `yaml:"dir,omitempty"` // the outbox with yaml storage, defaults to data/mail
+}
+
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	User     string
+	Password string
+}
+
+type OutboxMailer struct {
+	From string
+}
+
+var _mailer Mailer
+
+func Mail() Mailer {
+	if _mailer == nil {
+		conf := Config().Mail
+		from := conf.From
+		if from == "" {
+			from = "noreply@localhost"
+		}
+		if conf.SMTP != "" {
+			_mailer = &SMTPMailer{Addr: conf.SMTP, From: from, User: conf.User, Password: conf.Password}
+			Log(LOG_AUTH).Info("sending mail through smtp", "smtp", conf.SMTP)
+		} else {
+			_mailer = &OutboxMailer{From: from}
+			Log(LOG_AUTH).Warn("no smtp server configured, mail goes to the outbox")
+		}
+	}
+	return _mailer
+}
+
+func mail_message(from string, to string, subject string, body string) []byte {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		from, to, subject, time.Now().Format(time.RFC1123Z), strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(msg)
+}
+
+// Headers come from players (well, their email address), no sneaking in more.
+func mail_header_ok(str string) bool {
+	return !strings.ContainsAny(str, "\r\n")
+}
+
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	if !mail_header_ok(to) || !mail_header_ok(subject) {
+		return Err("invalid mail header")
+	}
+	var auth smtp.Auth
+	if m.User != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.User, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, mail_message(m.From, to, subject, body))
+}
+
+func (m *OutboxMailer) Send(to string, subject string, body string) error {
+	if !mail_header_ok(to) || !mail_header_ok(subject) {
+		return Err("invalid mail header")
+	}
+	return Storage().SaveMail(&MailData{Sent: time.Now(), To: to, Subject: subject, Message: string(mail_message(m.From, to, subject, body))})
+}
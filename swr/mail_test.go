@@ -0,0 +1,115 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mail_test_token = regexp.MustCompile(`\r\n    ([a-z2-7]+)\r\n`)
+
+// The last token mailed to to, out of the outbox.
+func mail_test_outbox(t *testing.T, to string) (int, string) {
+	mails, err := Storage().Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, token := 0, ""
+	for _, mail := range mails {
+		if mail.To != to {
+			continue
+		}
+		n++
+		if !strings.Contains(mail.Subject, "password reset") {
+			t.Errorf("subject is %q", mail.Subject)
+		}
+		m := mail_test_token.FindStringSubmatch(mail.Message)
+		if m == nil {
+			t.Fatalf("no token in %q", mail.Message)
+		}
+		token = m[1]
+	}
+	return n, token
+}
+
+// forgot at the password prompt, all the way through the outbox and back.
+func TestResetToken(t *testing.T) {
+	test_boot()
+	orig := _mailer
+	defer func() { _mailer = orig }()
+	_mailer = &OutboxMailer{From: "noreply@localhost"}
+
+	account := account_create("resettest", "whatever", "resettest@example.com")
+	if account == nil {
+		t.Fatal("couldn't make the account")
+	}
+	defer DB().db.Unscoped().Delete(account)
+
+	if account.CheckResetToken("") {
+		t.Fatal("no token sent and an empty one works")
+	}
+	if err := account.SendResetToken(); err != nil {
+		t.Fatal(err)
+	}
+	n, token := mail_test_outbox(t, "resettest@example.com")
+	if n != 1 || token == "" {
+		t.Fatalf("%d mails in the outbox, token %q", n, token)
+	}
+	if d := time.Until(account_find("resettest").ResetExpires); d > RESET_TOKEN_TTL || d < RESET_TOKEN_TTL-time.Minute {
+		t.Errorf("token good for %v, wanted %v", d, RESET_TOKEN_TTL)
+	}
+	if strings.Contains(account_find("resettest").ResetToken, token) {
+		t.Error("the token is saved as it is, not hashed")
+	}
+
+	// somebody hammering forgot
+	if err := account.SendResetToken(); err == nil {
+		t.Error("sent another token right away")
+	}
+	if n, _ := mail_test_outbox(t, "resettest@example.com"); n != 1 {
+		t.Errorf("%d mails in the outbox, wanted 1", n)
+	}
+
+	if account_find("resettest").CheckResetToken("notthetoken") {
+		t.Error("the wrong token works")
+	}
+	if !account_find("resettest").CheckResetToken(" " + strings.ToUpper(token) + " ") {
+		t.Fatal("the token doesn't work")
+	}
+	if account_find("resettest").CheckResetToken(token) {
+		t.Error("the token works twice")
+	}
+
+	// once it's used up another can be sent, and that one runs out
+	account = account_find("resettest")
+	if err := account.SendResetToken(); err != nil {
+		t.Fatal(err)
+	}
+	n, token = mail_test_outbox(t, "resettest@example.com")
+	if n != 2 {
+		t.Fatalf("%d mails in the outbox, wanted 2", n)
+	}
+	account.ResetExpires = time.Now().Add(-time.Second)
+	account.Save()
+	if account_find("resettest").CheckResetToken(token) {
+		t.Error("the token works after it ran out")
+	}
+}
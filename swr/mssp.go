@@ -0,0 +1,99 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	MSSP_VAR = byte(1)
+	MSSP_VAL = byte(2)
+)
+
+// What the mud listing sites see, https://tintin.mudhalla.net/protocols/mssp/
+// Keys are sent in this order.
+func mssp_variables() [][2]string {
+	stats := DB().Stats()
+	port := ""
+	if _, p, err := net.SplitHostPort(Config().Addr); err == nil {
+		port = p
+	}
+	return [][2]string{
+		{"NAME", Config().Name},
+		{"PLAYERS", sprintf("%d", stats.Players)},
+		{"UPTIME", sprintf("%d", startup.Unix())},
+		{"CODEBASE", "SWR " + GetVersion()},
+		{"PORT", port},
+		{"AREAS", sprintf("%d", stats.Areas)},
+		{"ROOMS", sprintf("%d", stats.Rooms)},
+		{"MOBILES", sprintf("%d", stats.Mobs)},
+		{"SHIPS", sprintf("%d", stats.Ships)},
+		{"FAMILY", "DikuMUD"},
+		{"GENRE", "Science Fiction"},
+		{"GAMESYSTEM", "Custom"},
+		{"LANGUAGE", "English"},
+		{"ANSI", "1"},
+		{"UTF-8", "1"},
+		{"XTERM 256 COLORS", "1"},
+		{"XTERM TRUE COLORS", "1"},
+		{"GMCP", "1"},
+		{"MCCP", "1"},
+		{"MSDP", "0"},
+		{"SSL", "0"},
+	}
+}
+
+// Answer DO MSSP with the variable table.
+func mssp_telnet_init(t *Telnet) {
+	t.Support(NET_MSSP, true, false)
+	t.Handle(NET_MSSP, &TelnetHandler{
+		OnEnable: func(local bool) {
+			if !local {
+				return
+			}
+			buf := make([]byte, 0, 512)
+			for _, v := range mssp_variables() {
+				buf = append(buf, MSSP_VAR)
+				buf = append(buf, v[0]...)
+				buf = append(buf, MSSP_VAL)
+				buf = append(buf, v[1]...)
+			}
+			t.Subnegotiate(NET_MSSP, buf)
+		},
+	})
+	t.EnableLocal(NET_MSSP)
+}
+
+// Crawlers that don't speak telnet send MSSP-REQUEST as their first line and
+// expect the same table back as plain text. Returns true if that's what this
+// was, the client is hung up on afterwards.
+func mssp_request(client Client, input string) bool {
+	if strings.TrimSpace(input) != "MSSP-REQUEST" {
+		return false
+	}
+	buf := "\r\nMSSP-REPLY-START\r\n"
+	for _, v := range mssp_variables() {
+		buf += v[0] + "\t" + v[1] + "\r\n"
+	}
+	buf += "MSSP-REPLY-END\r\n"
+	client.Raw([]byte(buf))
+	client.Close()
+	return true
+}
@@ -21,6 +21,7 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/robertkrimen/otto"
@@ -61,6 +62,7 @@ type Brain interface {
 type GenericBrain struct {
 	Entity Entity
 	vm     *otto.Otto
+	m      *sync.Mutex // one program at a time, otto isn't safe to share
 }
 
 // MakeGenericBrain creates a [*GenericBrain] instance and wraps the entity in it. Effectively passing control to the brain.
@@ -68,35 +70,44 @@ func MakeGenericBrain(entity Entity) *GenericBrain {
 	brain := new(GenericBrain)
 	brain.Entity = entity
 	brain.vm = mud_prog_init(entity)
+	brain.m = &sync.Mutex{}
 	return brain
 }
 
 func (b *GenericBrain) OnSpawn() {
-	go mud_prog_exec(b.vm, "spawn", b.Entity)
+	go b.exec("spawn")
 }
 func (b *GenericBrain) OnDeath() {
-	go mud_prog_exec(b.vm, "death", b.Entity)
+	go b.exec("death")
 }
 func (b *GenericBrain) OnKill(entity Entity) {
-	go mud_prog_exec(b.vm, "kill", b.Entity, entity)
+	go b.exec("kill", entity)
 }
 func (b *GenericBrain) OnMove(entity Entity) {
-	go mud_prog_exec(b.vm, "move", b.Entity, entity)
+	go b.exec("move", entity)
 }
 func (b *GenericBrain) OnGreet(entity Entity) {
-	go mud_prog_exec(b.vm, "greet", b.Entity, entity)
+	go b.exec("greet", entity)
 }
 func (b *GenericBrain) OnDrop(entity Entity, item Item) {
-	go mud_prog_exec(b.vm, "drop", b.Entity, entity, item)
+	go b.exec("drop", entity, item)
 }
 func (b *GenericBrain) OnGive(entity Entity, quantity int, item Item) {
-	go mud_prog_exec(b.vm, "give", b.Entity, entity, quantity, item)
+	go b.exec("give", entity, quantity, item)
 }
 func (b *GenericBrain) OnHeal(entity Entity) {
-	go mud_prog_exec(b.vm, "heal", b.Entity, entity)
+	go b.exec("heal", entity)
 }
 func (b *GenericBrain) OnSay(entity Entity, words string) {
-	go mud_prog_exec(b.vm, "say", b.Entity, entity, words)
+	go b.exec("say", entity, words)
+}
+
+// Progs run on their own goroutine since they can delay(), anything they do to
+// the world goes through game_sync.
+func (b *GenericBrain) exec(prog string, any ...interface{}) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	mud_prog_exec(b.vm, prog, b.Entity, any...)
 }
 
 /* Update is called every server tick, it's the main logic tree for AI and {GenericBrain}
@@ -117,8 +128,8 @@ func (b *GenericBrain) Update() {
 }
 
 // Move makes the brain perform a move action.
-// It will move it's controlling entity to another room using do_direction,
-// same as a player.
+// It will move it's controlling entity to another room through do_command,
+// same as a player, so a sleeping or fighting mob stays put.
 func (b *GenericBrain) Move() {
 	room := b.Entity.GetRoom()
 	total_exits := len(room.Exits)
@@ -130,7 +141,7 @@ func (b *GenericBrain) Move() {
 			// this prevents mobs getting stuck in "turbolift" rooms
 			to_room := DB().GetRoom(e, room.ship)
 			if len(to_room.Exits) > 0 {
-				do_direction(b.Entity, i)
+				do_command(b.Entity, i)
 			}
 		}
 		count++
@@ -141,20 +152,42 @@ func (b *GenericBrain) Move() {
 	mud_prog_exec takes a string, an entity, and various argument types (entity, item, string) and
 
 initializes a javascript vm, sets the variables, and executes the script. This is the main
-function for AI scripts the mud universe calls mudprogs. Any script errors will be visible
-in the console.
+function for AI scripts the mud universe calls mudprogs. Any script errors go to the
+log, and the progs wiznet channel.
 */
 func mud_prog_exec(vm *otto.Otto, prog string, entity Entity, any ...interface{}) error {
 	ch := entity.GetCharData()
 	if pg, ok := ch.Progs[prog]; ok {
 		mud_prog_bind(vm, any...)
-		_, err := vm.Run(pg)
-		ErrorCheck(err)
+		err := mud_prog_run(vm, pg, entity, any...)
+		if err != nil {
+			Log(LOG_PROGS).Wiz(WIZ_PROGS).Error("mudprog failed", "mob", ch.Name, "id", ch.Id, "prog", prog, "err", err)
+		}
 		return err
 	}
 	return Err("%s is not a program of [%d]%s", prog, ch.Id, ch.Name)
 }
 
+// Runs a prog so that a broken one only takes itself down. Whoever set it off
+// is told something went wrong.
+func mud_prog_run(vm *otto.Otto, src string, entity Entity, any ...interface{}) (err error) {
+	defer func() {
+		if recover_panic(recover(), "mudprog", "entity", entity.GetCharData().Name) {
+			err = Err("mudprog of %s panicked", entity.GetCharData().Name)
+			for _, a := range append([]interface{}{entity}, any...) {
+				if e, ok := a.(Entity); ok && e.IsPlayer() {
+					game_sync(func() {
+						e.Send("\r\n&RSomething went wrong there, the immortals have been told.&d\r\n")
+					})
+					break
+				}
+			}
+		}
+	}()
+	_, err = vm.Run(src)
+	return err
+}
+
 /*
 	mud_prog_bind sets various variables for the [GenericBrain] AI script executor.
 
@@ -198,29 +231,39 @@ func mud_prog_init(entity Entity) *otto.Otto {
 	}
 	// say("hello");
 	vm.Set("say", func(call otto.FunctionCall) otto.Value {
-		do_say(entity, call.Argument(0).String())
+		game_sync(func() {
+			do_say(entity, call.Argument(0).String())
+		})
 		return otto.Value{}
 	})
 	// shout("Stop!");
 	vm.Set("shout", func(call otto.FunctionCall) otto.Value {
-		do_shout(entity, call.Argument(0).String())
+		game_sync(func() {
+			do_shout(entity, call.Argument(0).String())
+		})
 		return otto.Value{}
 	})
 	// emote("sits down");
 	vm.Set("emote", func(call otto.FunctionCall) otto.Value {
-		do_emote(entity, call.Argument(0).String())
+		game_sync(func() {
+			do_emote(entity, call.Argument(0).String())
+		})
 		return otto.Value{}
 	})
 	// echo("straight to the terminal")
 	vm.Set("echo", func(call otto.FunctionCall) otto.Value {
-		entity.Send(call.Argument(0).String())
+		game_sync(func() {
+			entity.Send(call.Argument(0).String())
+		})
 		return otto.Value{}
 	})
 	// transfer($n, 100);  - $n is the player, 100 is the room_id
 	vm.Set("transfer", func(call otto.FunctionCall) otto.Value {
 		entity_name := call.Argument(0).String()
 		room_value, _ := call.Argument(1).ToInteger()
-		do_transfer(entity, entity_name, strconv.Itoa(int(room_value)))
+		game_sync(func() {
+			do_transfer(entity, entity_name, strconv.Itoa(int(room_value)))
+		})
 		return otto.Value{}
 	})
 	// delay(2);  - delay($n); where $n is an integer. delay will sleep the goroutine for $n seconds.
@@ -262,42 +305,54 @@ func mud_prog_init(entity Entity) *otto.Otto {
 	})
 	// look();...  not sure how useful this is to the entity, maybe rework it so it makes the player ($n) perform a do_look...
 	vm.Set("look", func(call otto.FunctionCall) otto.Value {
-		do_look(entity)
+		game_sync(func() {
+			do_look(entity)
+		})
 		return otto.Value{}
 	})
 	// kill($n);  - makes the entity fight $n. Like scott pilgrim.
 	vm.Set("kill", func(call otto.FunctionCall) otto.Value {
 		target, _ := call.Argument(0).ToString()
-		do_fight(entity, target)
+		game_sync(func() {
+			do_fight(entity, target)
+		})
 		return otto.Value{}
 	})
 	// stand();  -  makes the entity stand up.
 	vm.Set("stand", func(call otto.FunctionCall) otto.Value {
-		do_stand(entity)
+		game_sync(func() {
+			do_stand(entity)
+		})
 		return otto.Value{}
 	})
 	// sit();  -  makes the entity stand up.
 	vm.Set("sit", func(call otto.FunctionCall) otto.Value {
-		do_sit(entity)
+		game_sync(func() {
+			do_sit(entity)
+		})
 		return otto.Value{}
 	})
 	vm.Set("give", func(call otto.FunctionCall) otto.Value {
 		entity_name, _ := call.Argument(0).ToString()
 		id, _ := call.Argument(1).ToInteger()
-		item := DB().GetItem(uint(id))
-		if item != nil {
+		given := true
+		game_sync(func() {
+			item := DB().GetItem(uint(id))
+			if item == nil {
+				return
+			}
 			for _, e := range entity.GetRoom().GetEntities() {
 				if e.GetCharData().Name == entity_name {
 					if e.GetCharData().CurrentInventoryCount() >= e.GetCharData().MaxInventoryCount() {
-						v, _ := otto.ToValue(false)
-						return v
+						given = false
+						return
 					}
 					e.GetCharData().Inventory = append(e.GetCharData().Inventory, item.(*ItemData))
 					e.Send("\r\n&Y have received &W%s&Y.&d\r\n", item.GetData().Name)
 				}
 			}
-		}
-		v, _ := otto.ToValue(true)
+		})
+		v, _ := otto.ToValue(given)
 		return v
 	})
 
@@ -18,28 +18,39 @@
 package swr
 
 import (
+	"bufio"
+	"compress/zlib"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"os"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
 )
 
 const (
-	NET_IAC  = byte(255)
-	NET_WILL = byte(251)
-	NET_WONT = byte(252)
-	NET_DO   = byte(253)
-	NET_DONT = byte(254)
-	NET_ECHO = byte(1)
-	NET_GA   = byte(3)
+	NET_IAC   = byte(255)
+	NET_WILL  = byte(251)
+	NET_WONT  = byte(252)
+	NET_DO    = byte(253)
+	NET_DONT  = byte(254)
+	NET_SB    = byte(250)
+	NET_SE    = byte(240)
+	NET_ECHO  = byte(1)
+	NET_GA    = byte(3) // really the SGA option, kept for old callers
+	NET_SGA   = byte(3)
+	NET_NAWS  = byte(31)
+	NET_TTYPE = byte(24)
+	NET_GMCP  = byte(201)
+	NET_MCCP2 = byte(86)
+	NET_MSSP  = byte(70)
 )
 
 var ServerRunning bool = false
-var ServerQueue chan MudClientCommand = make(chan MudClientCommand)
+var ServerQueue chan MudClientCommand = make(chan MudClientCommand, 256)
 
 type MudClientCommand struct {
 	Entity  Entity
@@ -50,85 +61,193 @@ type TCPClient struct {
 	Id      string
 	Con     *net.TCPConn
 	fd      *os.File
-	Closed  bool
-	Idle    int
-	Editing bool
+	reader  *bufio.Reader
+	telnet  *Telnet
+	gmcp    *GMCPSupports
+	wm      *sync.Mutex  // guards writes, the game and the reader both write
+	zlib    *zlib.Writer // non-nil once MCCP2 compression has started
+	pending bool         // written to zlib since the last flush
+	width   int32        // columns, from NAWS
+	caps    uint32       // TermCaps, from TTYPE/MTTS
+	cr      bool
+	input   *InputQueue
+	closed  int32 // atomic, the game loop and the connection both look
+	idle    int32
+	editing int32
 	EditPtr *string
 	Queue   []string
 }
 
+func NewTCPClient(con *net.TCPConn) *TCPClient {
+	fd, _ := con.File()
+	client := new(TCPClient)
+	client.Id = hex.EncodeToString([]byte(con.RemoteAddr().String()))
+	client.Con = con
+	client.fd = fd
+	client.reader = bufio.NewReader(con)
+	client.wm = &sync.Mutex{}
+	client.width = TERM_WIDTH_DEFAULT
+	client.caps = uint32(TERM_CAPS_DEFAULT)
+	client.telnet = NewTelnet(client.Raw)
+	client.gmcp = NewGMCPSupports()
+	client.input = NewInputQueue()
+	return client
+}
+
 func (c *TCPClient) Send(str string) {
-	str = Color().Colorize(str)
-	if c.Editing {
+	str = Color().Render(str, c.GetCaps())
+	// a raw 255 in the text would be read as IAC by the client
+	str = strings.ReplaceAll(str, "\xff", "\xff\xff")
+	if c.IsEditing() {
 		c.Queue = append(c.Queue, str)
 	} else {
-		_, e := c.Con.Write([]byte(str))
+		e := c.write([]byte(str))
 		if e == io.EOF {
 			c.Close()
 		}
 		if e == io.ErrClosedPipe {
 			c.Close()
 		}
-		if e == net.ErrClosed {
+		if errors.Is(e, net.ErrClosed) {
 			c.Close()
 		}
 	}
 
 }
 
+// Everything bound for the socket goes through here. Once MCCP2 is on the bytes
+// go through the zlib stream instead, and sit in the compressor until Flush, so
+// a pulse worth of output goes out as one compressed block rather than a sync
+// flush after every line.
+func (c *TCPClient) write(buffer []byte) error {
+	c.wm.Lock()
+	defer c.wm.Unlock()
+	if c.zlib == nil {
+		_, e := c.Con.Write(buffer)
+		return e
+	}
+	if _, e := c.zlib.Write(buffer); e != nil {
+		return e
+	}
+	c.pending = true
+	return nil
+}
+
+// Pushes out whatever is waiting in the compressor. The game loop calls it at
+// the end of every pulse, and Read before it waits on the player, so a prompt
+// (or a half line like "Password: ") shows up right away.
+func (c *TCPClient) Flush() {
+	c.wm.Lock()
+	var e error
+	if c.zlib != nil && c.pending {
+		e = c.zlib.Flush()
+		c.pending = false
+	}
+	c.wm.Unlock()
+	if e != nil {
+		c.Close()
+	}
+}
+
+// Start MCCP2, everything after IAC SB COMPRESS2 IAC SE is compressed.
+func (c *TCPClient) compress_start() {
+	c.wm.Lock()
+	defer c.wm.Unlock()
+	if c.zlib != nil || c.IsClosed() {
+		return
+	}
+	if _, e := c.Con.Write([]byte{NET_IAC, NET_SB, NET_MCCP2, NET_IAC, NET_SE}); e != nil {
+		return
+	}
+	c.zlib, _ = zlib.NewWriterLevel(c.Con, zlib.BestSpeed)
+}
+
+// Finish the zlib stream, the client drops back to plain text after Z_FINISH.
+// Returns true if compression was running.
+func (c *TCPClient) compress_end() bool {
+	c.wm.Lock()
+	defer c.wm.Unlock()
+	if c.zlib == nil {
+		return false
+	}
+	ErrorCheck(c.zlib.Close())
+	c.zlib = nil
+	c.pending = false
+	return true
+}
+
 func (c *TCPClient) Sendf(format string, any ...interface{}) {
 	c.Send(fmt.Sprintf(format, any...))
 }
 func (c *TCPClient) ReadRaw(b []byte) (int, error) {
-	return c.Con.Read(b)
+	c.Flush()
+	return c.reader.Read(b)
 }
+
+// Read returns the next line of player input. Telnet commands are pulled out of
+// the stream by the negotiation layer as they arrive, so a client answering a
+// DO/WILL halfway through a line doesn't mangle what the player typed.
 func (c *TCPClient) Read() string {
-	b := make([]byte, 1)
-	buf := ""
+	buf := make([]byte, 0, 64)
 	for {
-		if c.Closed {
+		if c.IsClosed() {
 			break
 		}
-		if c.Editing {
+		if c.IsEditing() {
 			break
 		}
-		i, err := c.Con.Read(b)
+		if c.reader.Buffered() == 0 {
+			// about to wait on the player, they'd better see what they're answering
+			c.Flush()
+		}
+		b, err := c.reader.ReadByte()
 		if err != nil {
 			c.Close()
-			c.Con.Close()
-			return buf
+			return string(buf)
 		}
-		if i > 0 {
-			buf += string(b)
-			if strings.HasSuffix(buf, "\n") {
-				buf = strings.TrimSuffix(buf, "\r\n")
-				buf = strings.TrimSuffix(buf, "\n")
-				return strings.TrimSpace(buf)
-			}
+		d, ok := c.telnet.Feed(b)
+		if !ok {
+			continue
+		}
+		// CR LF, CR NUL and bare LF all end a line
+		if d == '\n' && c.cr {
+			c.cr = false
+			continue
+		}
+		c.cr = d == '\r'
+		if d == '\r' || d == '\n' {
+			return strings.TrimSpace(string(buf))
+		}
+		if d == 0 {
+			continue
 		}
+		buf = append(buf, d)
 	}
-	return buf
+	return string(buf)
 }
 
 func (c *TCPClient) Close() {
-	c.Closed = true
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return
+	}
+	c.compress_end()
 	c.fd.Close()
 	c.Con.Close()
 }
 
 func (c *TCPClient) IsClosed() bool {
-	return c.Closed
+	return atomic.LoadInt32(&c.closed) == 1
 }
 
 func (c *TCPClient) BufferEditor(str *string) {
-	if !c.Editing {
+	if !c.IsEditing() {
 		c.EditPtr = str
-		c.Editing = true
+		c.SetEditing(true)
 	}
 }
 
 func (c *TCPClient) Raw(buffer []byte) {
-	_, e := c.Con.Write(buffer)
+	e := c.write(buffer)
 	if e == io.EOF {
 		c.Close()
 		return
@@ -142,23 +261,53 @@ func (c *TCPClient) GetId() string {
 	return c.Id
 }
 
+func (c *TCPClient) GetWidth() int {
+	return int(atomic.LoadInt32(&c.width))
+}
+
+func (c *TCPClient) GetCaps() TermCaps {
+	return TermCaps(atomic.LoadUint32(&c.caps))
+}
+
+func (c *TCPClient) GetAddr() string {
+	return addr_host(c.Con.RemoteAddr())
+}
+
+func (c *TCPClient) Input() *InputQueue {
+	return c.input
+}
+
+func (c *TCPClient) Telnet() *Telnet {
+	return c.telnet
+}
+
+func (c *TCPClient) SendGMCP(pkg string, data interface{}) {
+	if c.IsClosed() || !c.telnet.IsLocal(NET_GMCP) || !c.gmcp.Wants(pkg) {
+		return
+	}
+	c.telnet.Subnegotiate(NET_GMCP, gmcp_encode(pkg, data))
+}
+
 func (c *TCPClient) SetEditing(editing bool) {
-	c.Editing = editing
+	atomic.StoreInt32(&c.editing, bool_int32(editing))
 }
 
 func (c *TCPClient) IsEditing() bool {
-	return c.Editing
+	return atomic.LoadInt32(&c.editing) == 1
 }
 
 func (c *TCPClient) IdleInc() {
-	c.Idle++
+	atomic.AddInt32(&c.idle, 1)
 }
 func (c *TCPClient) GetIdle() int {
-	return c.Idle
+	return int(atomic.LoadInt32(&c.idle))
+}
+func (c *TCPClient) IdleReset() {
+	atomic.StoreInt32(&c.idle, 0)
 }
 func (c *TCPClient) SendQueue() {
 	for _, s := range c.Queue {
-		c.Con.Write([]byte(s))
+		c.write([]byte(s))
 	}
 }
 func (c *TCPClient) ClearQueue() {
@@ -179,8 +328,16 @@ type Client interface {
 	IsEditing() bool
 	IdleInc()
 	GetIdle() int
+	IdleReset()
 	SendQueue()
 	ClearQueue()
+	Telnet() *Telnet // nil if the client doesn't speak telnet
+	SendGMCP(pkg string, data interface{})
+	GetWidth() int // terminal columns, TERM_WIDTH_DEFAULT if we don't know
+	GetCaps() TermCaps
+	Input() *InputQueue // lines waiting for the game loop
+	Flush()             // sends anything the client is holding on to, like compressed output
+	GetAddr() string    // remote ip, for bans and login throttling
 }
 
 func ServerStart(addr string) {
@@ -188,100 +345,103 @@ func ServerStart(addr string) {
 	l, err := net.ListenTCP("tcp", a)
 	ErrorCheck(err)
 	defer l.Close()
-	log.Printf("Listening for connections on %s\n", addr)
+	Log(LOG_NET).Info("listening", "addr", addr, "via", "telnet")
 	ServerRunning = true
-	go processClients()
-	go processServerPump()
+	if Config().WebAddr != "" {
+		go WebServerStart(Config().WebAddr)
+	}
+	if Config().SSHAddr != "" {
+		go SSHServerStart(Config().SSHAddr)
+	}
+	go processGameLoop()
 	for {
 		if !ServerRunning {
 			break
 		}
 		c, err := l.AcceptTCP()
 		if err != nil {
-			log.Printf("Error accepting a connection: %v", err)
+			Log(LOG_NET).Warn("error accepting a connection", "err", err)
 			continue
 		}
 		if c != nil {
 			go acceptClient(c)
-			log.Printf("Accepted client connection from %s", c.RemoteAddr())
 		}
 
 	}
 	ServerRunning = false
 }
-func processClients() {
-	for {
-		if !ServerRunning {
-			break
-		}
-		cmd := <-ServerQueue
-		do_command(cmd.Entity, cmd.Command)
-		time.Sleep(500 * time.Millisecond)
-	}
-}
-func processServerPump() {
-	for {
-		if !ServerRunning {
-			break
-		}
-		processIdleClients()
-		processCombat()
-		processEntities()
-		updateMinerDifficulty()
-		time.Sleep(1 * time.Second)
+func acceptClient(con *net.TCPConn) {
+	client := NewTCPClient(con)
+	Log(LOG_NET).Info("connection accepted", "addr", client.GetAddr(), "via", "telnet")
+	if ban_check_site(client) {
+		return
 	}
-	log.Printf("Server Pump has exited!\n")
+	telnet_negotiate(client)
+	client_session(client, auth_do_welcome)
 }
-func acceptClient(con *net.TCPConn) {
-	fd, _ := con.File()
+
+// Everything after the socket is accepted is the same for every kind of client,
+// telnet, websocket, ssh. Login, then pump input until they leave.
+func client_session(client Client, login func(client Client)) {
 	db := DB()
-	client := new(TCPClient)
-	client.Id = hex.EncodeToString([]byte(con.RemoteAddr().String()))
-	client.Con = con
-	client.fd = fd
-	client.Closed = false
-	client.Idle = 0
+	defer func() {
+		// a bug in here only costs this connection, not the server
+		if recover_panic(recover(), "client session", "addr", client.GetAddr()) {
+			client.Close()
+			game_sync(func() {
+				db.RemoveClient(client)
+			})
+		}
+	}()
 	db.AddClient(client)
-	auth_do_welcome(client)
-	if client.Closed {
-		return
-	}
+	login(client)
 	entity := db.GetEntityForClient(client)
-	if entity == nil {
-		con.Close()
-		db.RemoveClient(client)
+	if entity == nil || client.IsClosed() {
+		client.Close()
+		game_sync(func() {
+			db.RemoveClient(client)
+		})
 		return
 	}
 	for {
 		if !ServerRunning {
 			break
 		}
-		if client.Closed {
+		if client.IsClosed() {
 			break
 		} else {
 			input := client.Read()
 			if len(input) > 0 {
-				ServerQueue <- MudClientCommand{
-					Entity:  entity,
-					Command: input,
+				if !client.Input().Push(input) {
+					client.Send("\r\n&RSlow down! You're typing faster than the galaxy can keep up.&d\r\n")
 				}
-				client.Idle = 0
+				client.IdleReset()
 			}
 		}
 	}
-	log.Printf("Player %s has left the game.", entity.GetCharData().Name)
-	db.RemoveClient(client)
-	room := DB().GetRoom(entity.RoomId(), entity.ShipId())
-	room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has left.\r\n", entity.GetCharData().Name))
-	con.Close()
+	client.Close()
+	game_sync(func() {
+		if player, ok := entity.(*PlayerProfile); ok && ServerRunning {
+			if player.Client != client {
+				// somebody logged in over them
+				db.DropClient(client)
+				return
+			}
+			if db.GetEntityForClient(client) != nil {
+				db.DropClient(client)
+				player_linkdead(player)
+				return
+			}
+		}
+		Log(LOG_AUTH).Wiz(WIZ_LOGINS).Info(sprintf("%s has left the game", entity.GetCharData().Name), "addr", client.GetAddr())
+		db.RemoveClient(client)
+		room := DB().GetRoom(entity.RoomId(), entity.ShipId())
+		room.SendToRoom(fmt.Sprintf("\r\n&P%s&d has left.\r\n", entity.GetCharData().Name))
+	})
 }
 
 func processIdleClients() {
-	db := DB()
-	db.Lock()
-	defer db.Unlock()
-	for i := range db.clients {
-		client := db.clients[i]
+	for _, client := range DB().Clients() {
 		if client != nil {
 			client.IdleInc()
 			minuteSeconds := 60 * 60
@@ -303,52 +463,80 @@ func processIdleClients() {
 	}
 }
 
-//lint:ignore U1000 useful code
-func telnet_suppress_ga(con Client) {
-	con.Raw([]byte{NET_IAC, NET_WILL, NET_GA})
-	/*resp := make([]byte, 3)
-	n, e := con.ReadRaw(resp)
-	if e != nil {
-		con.Close()
+// Announce what we support, the client answers whenever it gets around to it.
+func telnet_negotiate(con Client) {
+	t := con.Telnet()
+	if t == nil {
+		return
+	}
+	t.Support(NET_ECHO, false, false)
+	t.EnableLocal(NET_SGA)
+	mssp_telnet_init(t)
+	if c, ok := con.(*TCPClient); ok {
+		gmcp_telnet_init(t, c.gmcp)
+		t.Support(NET_MCCP2, true, false)
+		t.Handle(NET_MCCP2, &TelnetHandler{
+			OnEnable: func(local bool) {
+				c.compress_start()
+			},
+			OnDisable: func(local bool) {
+				c.compress_end()
+			},
+		})
+		t.EnableLocal(NET_MCCP2)
+		t.Support(NET_NAWS, false, true)
+		t.Handle(NET_NAWS, &TelnetHandler{
+			OnSub: func(data []byte) {
+				if len(data) >= 4 {
+					w := term_width(int(data[0])<<8 | int(data[1]))
+					atomic.StoreInt32(&c.width, int32(w))
+				}
+			},
+		})
+		t.EnableRemote(NET_NAWS)
+		ttype_telnet_init(t, func(caps TermCaps) {
+			atomic.StoreUint32(&c.caps, uint32(caps))
+		})
 	}
-	if n != 3 {
-		panic(fmt.Sprintf("%v", resp))
+}
+
+func telnet_suppress_ga(con Client) {
+	if t := con.Telnet(); t != nil {
+		t.EnableLocal(NET_SGA)
 	}
-	if resp[0] != NET_IAC || resp[1] != NET_DONT || resp[2] != NET_GA {
-		panic(fmt.Sprintf("%v", resp))
-	} else {
-		log.Printf("SUPGA: %v", resp)
-	}*/
 }
 
-//lint:ignore U1000 useful code
 func telnet_unsuppress_ga(con Client) {
-	con.Raw([]byte{NET_IAC, NET_WONT, NET_GA})
-	/*resp := make([]byte, 3)
-	con.ReadRaw(resp)
-	if resp[0] != NET_IAC || resp[1] != NET_DO || resp[2] != NET_GA {
-		panic(fmt.Sprintf("%v", resp))
-	} else {
-		log.Printf("SUPGA: %v", resp)
-	}*/
+	if t := con.Telnet(); t != nil {
+		t.DisableLocal(NET_SGA)
+	}
+}
+
+// Clients that aren't telnet but still need to hide passwords (ssh, web).
+type echoClient interface {
+	SetEcho(echo bool)
 }
 
-//lint:ignore U1000 useful code
+// We WILL ECHO, which makes the client stop echoing what's typed (passwords).
 func telnet_disable_local_echo(con Client) {
-	con.Raw([]byte{NET_IAC, NET_WILL, NET_ECHO})
-	resp := make([]byte, 3)
-	con.ReadRaw(resp)
-	if resp[0] != NET_IAC || resp[1] != NET_DO || resp[2] != NET_ECHO {
-		log.Print("Error: client didn't respond to disable local echo properly!")
+	if t := con.Telnet(); t != nil {
+		t.EnableLocal(NET_ECHO)
+	} else if e, ok := con.(echoClient); ok {
+		e.SetEcho(false)
 	}
 }
 
-//lint:ignore U1000 useful code
 func telnet_enable_local_echo(con Client) {
-	con.Raw([]byte{NET_IAC, NET_WONT, NET_ECHO})
-	resp := make([]byte, 3)
-	con.ReadRaw(resp)
-	if resp[0] != NET_IAC || resp[1] != NET_DONT || resp[2] != NET_ECHO {
-		log.Print("Error: client didn't respond to enable local echo properly!")
+	if t := con.Telnet(); t != nil {
+		t.DisableLocal(NET_ECHO)
+	} else if e, ok := con.(echoClient); ok {
+		e.SetEcho(true)
+	}
+}
+
+func bool_int32(b bool) int32 {
+	if b {
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,121 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// A TCPClient on one end of a loopback connection, the other end for us.
+func test_tcp_pair(t *testing.T) (*TCPClient, *net.TCPConn) {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	remote, err := net.DialTCP("tcp", nil, l.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	con, err := l.AcceptTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewTCPClient(con)
+	t.Cleanup(func() {
+		client.Close()
+		remote.Close()
+	})
+	return client, remote
+}
+
+// Whatever turns up in the next little while.
+func test_tcp_drain(t *testing.T, con *net.TCPConn) []byte {
+	ret := make([]byte, 0)
+	buf := make([]byte, 4096)
+	for {
+		con.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := con.Read(buf)
+		ret = append(ret, buf[:n]...)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return ret
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Output sits in the compressor until the end of the pulse (or a Read), and
+// then it all goes in one flush.
+func TestMCCP2Flush(t *testing.T) {
+	client, remote := test_tcp_pair(t)
+	client.compress_start()
+	if got, want := test_tcp_drain(t, remote), []byte{NET_IAC, NET_SB, NET_MCCP2, NET_IAC, NET_SE}; !bytes.Equal(got, want) {
+		t.Fatalf("got %v, wanted %v", got, want)
+	}
+
+	client.Send("You are in a small room.\r\n")
+	client.Send("Obvious exits: north.\r\n")
+	stream := bytes.NewBuffer(test_tcp_drain(t, remote))
+	if stream.Len() > 2 { // the zlib header goes out with the first write
+		t.Errorf("%d bytes went out before the flush", stream.Len())
+	}
+	client.Flush()
+	stream.Write(test_tcp_drain(t, remote))
+	client.Flush()
+	if extra := test_tcp_drain(t, remote); len(extra) > 0 {
+		t.Errorf("flushing nothing sent %v", extra)
+	}
+	z, err := zlib.NewReader(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "You are in a small room.\r\nObvious exits: north.\r\n"
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(z, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+
+	// a prompt from the login goroutine shows up once it waits for the answer
+	client.Send("Password: ")
+	line := make(chan string)
+	go func() { line <- client.Read() }()
+	stream.Write(test_tcp_drain(t, remote))
+	got = make([]byte, len("Password: "))
+	if _, err := io.ReadFull(z, got); err != nil {
+		t.Fatalf("prompt never showed up: %v", err)
+	}
+	if string(got) != "Password: " {
+		t.Errorf("got %q, wanted the prompt", got)
+	}
+	remote.Write([]byte("hunter2\r\n"))
+	if l := <-line; l != "hunter2" {
+		t.Errorf("read %q", l)
+	}
+}
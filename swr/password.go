@@ -0,0 +1,107 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Passwords are argon2id with a random salt per password, stored the way
+// everybody else stores them:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+//
+// The salt in config.yml is a pepper. It is mixed into every new hash and never
+// stored next to it, so a copy of game.db alone isn't enough to start guessing.
+// Changing it locks everybody out.
+//
+// Old hashes are plain sha256 hex. They still work, and get rehashed the next
+// time the password is typed in.
+
+const (
+	ARGON2_MEMORY  = 19 * 1024 // KiB
+	ARGON2_TIME    = 2
+	ARGON2_THREADS = 1
+	ARGON2_SALT    = 16
+	ARGON2_KEY     = 32
+)
+
+func password_pepper(password string) []byte {
+	mac := hmac.New(sha256.New, []byte(Config().Salt))
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+func password_hash(password string) string {
+	salt := make([]byte, ARGON2_SALT)
+	if _, err := rand.Read(salt); err != nil {
+		ErrorCheck(err)
+		return ""
+	}
+	key := argon2.IDKey(password_pepper(password), salt, ARGON2_TIME, ARGON2_MEMORY, ARGON2_THREADS, ARGON2_KEY)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, ARGON2_MEMORY, ARGON2_TIME, ARGON2_THREADS,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+// Checks a password against a stored hash. The second return says the hash is
+// out of date (legacy sha256, or weaker argon2 settings than we use now) and
+// should be replaced with password_hash while we still have the password.
+func password_verify(password string, hash string) (bool, bool) {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		sum := sha256.Sum256([]byte(password))
+		ok := subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hash))) == 1
+		return ok, ok
+	}
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false
+	}
+	key := argon2.IDKey(password_pepper(password), salt, time, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(key, want) != 1 {
+		return false, false
+	}
+	return true, memory < ARGON2_MEMORY || time < ARGON2_TIME
+}
@@ -0,0 +1,97 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash := password_hash("hunter2")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") {
+		t.Fatalf("%s isn't argon2id", hash)
+	}
+	if other := password_hash("hunter2"); other == hash {
+		t.Error("the same password hashed the same twice, the salt isn't doing anything")
+	}
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"hunter2", true},
+		{"hunter3", false},
+		{"Hunter2", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		ok, rehash := password_verify(c.password, hash)
+		if ok != c.ok {
+			t.Errorf("%q: got %v, wanted %v", c.password, ok, c.ok)
+		}
+		if rehash {
+			t.Errorf("%q: a fresh hash wants rehashing", c.password)
+		}
+	}
+	for _, broken := range []string{"$argon2id$", "$argon2id$v=19$m=19456,t=2,p=1$!!!$!!!", "$argon2id$v=18$m=19456,t=2,p=1$AAAA$AAAA"} {
+		if ok, _ := password_verify("hunter2", broken); ok {
+			t.Errorf("%s let us in", broken)
+		}
+	}
+}
+
+// An old sha256 hash still logs in, once, and comes out the other side as
+// argon2id.
+func TestPasswordUpgrade(t *testing.T) {
+	test_boot()
+	sum := sha256.Sum256([]byte("hunter2"))
+	legacy := hex.EncodeToString(sum[:])
+	if ok, rehash := password_verify("hunter2", legacy); !ok || !rehash {
+		t.Fatalf("legacy hash: got %v %v, wanted true true", ok, rehash)
+	}
+	if ok, _ := password_verify("hunter3", legacy); ok {
+		t.Fatal("legacy hash let the wrong password in")
+	}
+
+	account := account_create("pwupgrade", "whatever", "")
+	if account == nil {
+		t.Fatal("couldn't make the account")
+	}
+	defer DB().db.Unscoped().Delete(account)
+	account.Password = legacy
+	account.Save()
+
+	if account_find("pwupgrade").CheckPassword("hunter3") {
+		t.Fatal("the wrong password got in")
+	}
+	if got := account_find("pwupgrade").Password; got != legacy {
+		t.Errorf("a failed login changed the hash to %s", got)
+	}
+	if !account_find("pwupgrade").CheckPassword("hunter2") {
+		t.Fatal("the right password didn't get in")
+	}
+	saved := account_find("pwupgrade").Password
+	if !strings.HasPrefix(saved, "$argon2id$") {
+		t.Fatalf("hash is still %s after logging in", saved)
+	}
+	if ok, rehash := password_verify("hunter2", saved); !ok || rehash {
+		t.Errorf("upgraded hash: got %v %v, wanted true false", ok, rehash)
+	}
+}
@@ -0,0 +1,137 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Everything the game writes to data/ goes through write_yaml. The file is
+// written next to the real one, synced and renamed over it, so a crash halfway
+// through leaves the old file and not half a new one. The first line says which
+// version of the layout the file is in:
+//
+//	# swr-schema: 1
+//
+// When the layout changes, bump SCHEMA_VERSION and add a migration that takes
+// a file from the version before. load_yaml runs whatever migrations a file
+// needs before decoding it, and it's written back in the new layout the next
+// time it's saved.
+
+const SCHEMA_VERSION = 1
+
+const schema_header = "# swr-schema: "
+
+var schema_re = regexp.MustCompile(`^# swr-schema: (\d+)`)
+
+// schema_migrations[n] takes a file from version n to n+1, it gets the whole
+// document and changes it in place.
+var schema_migrations = []func(path string, doc *yaml.Node) error{
+	// 0 -> 1: files from before there was a header, nothing else changed.
+	func(path string, doc *yaml.Node) error { return nil },
+}
+
+// The version a file says it is, 0 if it doesn't say.
+func schema_version(data []byte) int {
+	m := schema_re.FindSubmatch(data)
+	if m == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(string(m[1]))
+	return v
+}
+
+// Brings a file loaded as doc up to SCHEMA_VERSION.
+func schema_migrate(path string, data []byte, doc *yaml.Node) error {
+	v := schema_version(data)
+	if v > SCHEMA_VERSION {
+		return &LoadError{File: path, Line: 1, Err: Err("schema version %d is newer than this server (%d)", v, SCHEMA_VERSION), Fatal: true}
+	}
+	if v == SCHEMA_VERSION {
+		return nil
+	}
+	for ; v < SCHEMA_VERSION; v++ {
+		if err := schema_migrations[v](path, doc); err != nil {
+			return &LoadError{File: path, Err: Err("migrating from schema %d: %v", v, err), Fatal: true}
+		}
+	}
+	Log(LOG_DB).Debug("migrated file", "file", path, "from", schema_version(data), "to", SCHEMA_VERSION)
+	return nil
+}
+
+// v as yaml, with the schema header on top.
+func marshal_yaml(v interface{}) ([]byte, error) {
+	buf, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(schema_header+strconv.Itoa(SCHEMA_VERSION)+"\n"), buf...), nil
+}
+
+func write_yaml(path string, v interface{}) error {
+	buf, err := marshal_yaml(v)
+	if err != nil {
+		return err
+	}
+	return write_file_atomic(path, buf, 0644)
+}
+
+// The last step of write_file_atomic, the tests make it fail.
+var file_rename = os.Rename
+
+// Like os.WriteFile, except the file is either all the old one or all the new
+// one, whenever the lights go out.
+func write_file_atomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := fp.Name()
+	_, err = fp.Write(data)
+	if err == nil {
+		err = fp.Sync()
+	}
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = file_rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// and the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
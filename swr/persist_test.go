@@ -0,0 +1,137 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Every file under data/ loads, saves and loads again without losing
+// anything, what `swr verify` checks.
+func TestDataRoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	files := 0
+	err := filepath.Walk("data", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		path = filepath.ToSlash(path)
+		if info.IsDir() {
+			if path == "data/quarantine" || path == "data/logs" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yml") && !strings.HasSuffix(path, ".yaml") {
+			return nil
+		}
+		new := verify_kind(path)
+		if new == nil {
+			t.Errorf("%s: don't know what's in it", path)
+			return nil
+		}
+		files++
+		for _, d := range verify_file(path, filepath.Join(tmp, filepath.Base(path)), new) {
+			t.Errorf("%s: %s", path, d)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files == 0 {
+		t.Fatal("no files under data/")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "player.yml")
+	if err := write_file_atomic(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := write_file_atomic(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if buf, _ := os.ReadFile(path); string(buf) != "new" {
+		t.Errorf("got %q, wanted new", buf)
+	}
+
+	// the lights go out before the rename
+	file_rename = func(string, string) error { return errors.New("power cut") }
+	defer func() { file_rename = os.Rename }()
+	if err := write_file_atomic(path, []byte("half a fi"), 0644); err == nil {
+		t.Error("write didn't fail")
+	}
+	if buf, _ := os.ReadFile(path); string(buf) != "new" {
+		t.Errorf("got %q after a failed write, wanted the old new", buf)
+	}
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+	if len(files) != 1 {
+		t.Errorf("temp files left behind: %v", files)
+	}
+}
+
+func TestSchemaMigrate(t *testing.T) {
+	ran := 0
+	orig := schema_migrations
+	defer func() { schema_migrations = orig }()
+	schema_migrations = make([]func(string, *yaml.Node) error, SCHEMA_VERSION)
+	for i := range schema_migrations {
+		schema_migrations[i] = func(string, *yaml.Node) error {
+			ran++
+			return nil
+		}
+	}
+	cases := []struct {
+		name  string
+		data  string
+		ran   int
+		fatal bool
+	}{
+		{"no header", "name: x\n", SCHEMA_VERSION, false},
+		{"older", "# swr-schema: 0\nname: x\n", SCHEMA_VERSION, false},
+		{"current", schema_header + strconv.Itoa(SCHEMA_VERSION) + "\nname: x\n", 0, false},
+		{"newer", schema_header + strconv.Itoa(SCHEMA_VERSION+1) + "\nname: x\n", 0, true},
+	}
+	for _, c := range cases {
+		ran = 0
+		doc := new(yaml.Node)
+		if err := yaml.Unmarshal([]byte(c.data), doc); err != nil {
+			t.Fatal(err)
+		}
+		err := schema_migrate(c.name, []byte(c.data), doc)
+		if c.fatal {
+			var le *LoadError
+			if !errors.As(err, &le) || !le.Fatal {
+				t.Errorf("%s: wanted a fatal load error, got %v", c.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if ran != c.ran {
+			t.Errorf("%s: ran %d migrations, wanted %d", c.name, ran, c.ran)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// Race templates from data/races. Every race a mob can be is in there, the
+// playable ones show up at character creation.
+type RaceData struct {
+	Name      string         `yaml:"name"`
+	Playable  bool           `yaml:"playable"`
+	Weight    int            `yaml:"weight"`                   // kg, before anything is carried
+	Stats     []int          `yaml:"stats,flow"`               // modifiers to STR INT DEX WIS CON CHA
+	Min       []int          `yaml:"min,flow"`                 // stats never go below...
+	Max       []int          `yaml:"max,flow"`                 // ...or above these at creation
+	Speaking  string         `yaml:"speaking"`                 // the language they start out speaking
+	Languages map[string]int `yaml:"languages"`                // languages they start with, and how well
+	Skills    map[string]int `yaml:"skills,omitempty"`         // skills they start with
+	Room      uint           `yaml:"room"`                     // where they first wake up
+	Equipment []uint         `yaml:"equipment,flow,omitempty"` // item ids they start out carrying
+	Filename  string         `yaml:"-"`
+}
+
+const RACE_WEIGHT_DEFAULT = 75
+
+var Races []*RaceData = make([]*RaceData, 0)
+
+func RacesLoad() {
+	log.Printf("Loading races.")
+	flist, err := os.ReadDir("data/races")
+	ErrorCheck(err)
+	for _, file := range flist {
+		if !strings.HasSuffix(file.Name(), ".yml") {
+			continue
+		}
+		r := new(RaceData)
+		r.Filename = "data/races/" + file.Name()
+		if err := load_yaml(r.Filename, r); err != nil {
+			load_problem(err)
+			continue
+		}
+		for len(r.Stats) < 6 {
+			r.Stats = append(r.Stats, 0)
+		}
+		for len(r.Min) < 6 {
+			r.Min = append(r.Min, 3)
+		}
+		for len(r.Max) < 6 {
+			r.Max = append(r.Max, 18)
+		}
+		if r.Weight == 0 {
+			r.Weight = RACE_WEIGHT_DEFAULT
+		}
+		if r.Languages == nil {
+			r.Languages = make(map[string]int)
+		}
+		if r.Skills == nil {
+			r.Skills = make(map[string]int)
+		}
+		Races = append(Races, r)
+	}
+	log.Printf("%d races loaded.", len(Races))
+}
+
+func race_get(name string) *RaceData {
+	for _, r := range Races {
+		if strings.EqualFold(r.Name, name) {
+			return r
+		}
+	}
+	return nil
+}
+
+func race_playable() []*RaceData {
+	ret := make([]*RaceData, 0)
+	for _, r := range Races {
+		if r.Playable {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// The classic roll, 3d6 for STR and three 3-6s for the rest, before race
+// modifiers.
+func roll_stats() []int {
+	stats := make([]int, 6)
+	stats[ENTITY_STAT_STR] = rand_min_max(1, 6) + rand_min_max(1, 6) + rand_min_max(1, 6)
+	for i := ENTITY_STAT_INT; i <= ENTITY_STAT_CHA; i++ {
+		stats[i] = rand_min_max(3, 6) + rand_min_max(3, 6) + rand_min_max(3, 6)
+	}
+	return stats
+}
+
+// Applies the race modifiers to rolled (or bought) stats, kept within the
+// race limits.
+func (r *RaceData) ApplyStats(stats []int) []int {
+	ret := make([]int, len(stats))
+	for i := range stats {
+		ret[i] = stats[i] + r.Stats[i]
+		if ret[i] < r.Min[i] {
+			ret[i] = r.Min[i]
+		}
+		if ret[i] > r.Max[i] {
+			ret[i] = r.Max[i]
+		}
+	}
+	return ret
+}
+
+// Sets up a brand new character of this race: languages, skills, where they
+// start and what they carry.
+func (r *RaceData) Init(ch *CharData) {
+	ch.Race = r.Name
+	ch.Room = r.Room
+	if ch.Room == 0 {
+		ch.Room = 100
+	}
+	ch.Speaking = r.Speaking
+	if ch.Speaking == "" {
+		ch.Speaking = "basic"
+	}
+	ch.Languages = make(map[string]int)
+	for l, v := range r.Languages {
+		ch.Languages[l] = v
+	}
+	if _, ok := ch.Languages[ch.Speaking]; !ok {
+		ch.Languages[ch.Speaking] = 100
+	}
+	ch.Skills = make(map[string]int)
+	for s, v := range r.Skills {
+		ch.Skills[s] = v
+	}
+	for _, id := range r.Equipment {
+		item := DB().GetItem(id)
+		if item == nil {
+			log.Printf("Race %s starts with item %d, which doesn't exist", r.Name, id)
+			continue
+		}
+		ch.Inventory = append(ch.Inventory, item_clone(item).GetData())
+	}
+}
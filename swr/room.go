@@ -19,8 +19,8 @@ package swr
 
 import (
 	"fmt"
-	"log"
 	"strings"
+	"time"
 )
 
 type MobSpawn struct {
@@ -43,6 +43,7 @@ type AreaData struct {
 	Rooms    []RoomData  `yaml:"rooms"`
 	Mobs     []MobSpawn  `yaml:"mobs,omitempty"`
 	Items    []ItemSpawn `yaml:"items,omitempty"`
+	Filename string      `yaml:"-"`
 }
 type Area interface {
 	Delete() error
@@ -83,6 +84,7 @@ type RoomData struct {
 	RoomProgs map[string]string        `yaml:"roomProgs,omitempty"`
 	Area      *AreaData                `yaml:"-"`
 	Items     []Item                   `yaml:"-"`
+	dropped   map[uint]time.Time       // when items were left here, see [RoomData.PlaceItem]
 }
 
 type Room interface {
@@ -128,7 +130,20 @@ func (r *RoomData) HasExit(direction string) bool {
 }
 
 func (r *RoomData) AddItem(item Item) {
+	r.PlaceItem(item, time.Now())
+}
+
+// Puts an item in the room as if it was left there at dropped, the zero time
+// for things the area resets put there, which never expire.
+func (r *RoomData) PlaceItem(item Item, dropped time.Time) {
 	r.Items = append(r.Items, item)
+	if dropped.IsZero() {
+		return
+	}
+	if r.dropped == nil {
+		r.dropped = make(map[uint]time.Time)
+	}
+	r.dropped[item.GetId()] = dropped
 }
 
 func (r *RoomData) ShipId() uint {
@@ -145,10 +160,14 @@ func (r *RoomData) RemoveItem(item Item) {
 			idx = id
 		}
 	}
-	ret := make([]Item, len(r.Items)-1)
+	if idx < 0 {
+		return
+	}
+	ret := make([]Item, 0, len(r.Items)-1)
 	ret = append(ret, r.Items[:idx]...)
 	ret = append(ret, r.Items[idx+1:]...)
 	r.Items = ret
+	delete(r.dropped, item.GetId())
 }
 
 func (r *RoomData) FindItem(keyword string) Item {
@@ -413,7 +432,7 @@ func area_reset(area *AreaData) {
 		room_id := r.Id
 		room := db.GetRoom(room_id, 0)
 		if room == nil {
-			log.Printf("Error: roomId %d doesn't exist! area_reset(%s)", room_id, area.Name)
+			Log(LOG_DB).Warn("area resets a room that doesn't exist", "area", area.Name, "room", room_id)
 			continue
 		}
 		for dir, f := range r.ExitFlags {
@@ -441,6 +460,9 @@ func area_reset(area *AreaData) {
 	for _, spawn := range area.Items {
 		room := db.GetRoom(spawn.Room, 0)
 		item := db.GetItem(spawn.Item)
+		if room == nil || item == nil {
+			continue // Validate has said so already
+		}
 		exists := false
 		for _, i := range room.Items {
 			if i != nil {
@@ -450,13 +472,16 @@ func area_reset(area *AreaData) {
 			}
 		}
 		if !exists {
-			room.AddItem(item_clone(item))
+			room.PlaceItem(item_clone(item), time.Time{})
 		}
 	}
 	for i := range area.Mobs {
 		spawn := area.Mobs[i]
 		mob := db.GetMob(spawn.Mob) // grabs the mob template
-		if spawn.entity != nil {    // checks to see if we have a managed entity
+		if mob == nil || db.GetRoom(spawn.Room, 0) == nil {
+			continue // Validate has said so already
+		}
+		if spawn.entity != nil { // checks to see if we have a managed entity
 			if spawn.entity.GetCharData().State == ENTITY_STATE_DEAD { // is it dead?
 				//log.Printf("Removing dead entity %s\n", spawn.entity.GetCharData().Name)
 				//db.RemoveEntity(spawn.entity)
@@ -480,6 +505,7 @@ func area_reset(area *AreaData) {
 		}
 		area.Mobs[i] = spawn
 	}
+	Log(LOG_DB).Wiz(WIZ_RESETS).Debug(sprintf("%s has reset", area.Name))
 	ScheduleFunc(func() {
 		area_reset(area)
 	}, false, area.Reset)
@@ -520,13 +546,21 @@ func get_direction_string(direction string) string {
 	return direction
 }
 
+// Looks up the prog now, while the entity is still where the event happened,
+// and runs it on its own goroutine like mob progs.
 func room_prog_exec(entity Entity, evt string, any ...interface{}) {
 	room := DB().GetRoom(entity.RoomId(), entity.ShipId())
+	if room == nil {
+		return
+	}
 	if pg, ok := room.RoomProgs[evt]; ok {
 		vm := mud_prog_init(entity)
 		mud_prog_bind(vm, any...)
-		_, err := vm.Run(pg)
-		ErrorCheck(err)
+		go func() {
+			if err := mud_prog_run(vm, pg, entity, any...); err != nil {
+				Log(LOG_PROGS).Wiz(WIZ_PROGS).Error("room prog failed", "room", room.Id, "prog", evt, "err", err)
+			}
+		}()
 	}
 }
 
@@ -40,9 +40,10 @@ func ScheduleFunc(fn func(), repeat bool, time uint) {
 	Scheduler().Schedule(&sf)
 }
 
+// The scheduler is ticked once a second by the game loop, so scheduled
+// functions run on the game loop and can touch the world like any command.
 type SchedulerService struct {
 	m     *sync.Mutex
-	t     *time.Ticker
 	funcs []*ScheduledFunction
 	bt    time.Time
 }
@@ -52,24 +53,11 @@ var _scheduler *SchedulerService
 func Scheduler() *SchedulerService {
 	if _scheduler == nil {
 		log.Println("Starting Scheduler.")
-		now := time.Now().UTC()
-		delta := now.Sub(now.Truncate(time.Second))
-		time.Sleep(time.Second - delta)
 		_scheduler = &SchedulerService{
-			t:     time.NewTicker(time.Duration(1) * time.Second),
 			m:     &sync.Mutex{},
 			funcs: []*ScheduledFunction{},
+			bt:    time.Now().UTC(),
 		}
-		go func() {
-			for {
-				<-_scheduler.t.C
-				now := time.Now().UTC()
-				delta := now.Sub(now.Truncate(time.Second))
-				time.Sleep(time.Second - delta)
-				_scheduler.tick(time.Now().UTC())
-			}
-		}()
-		_scheduler.bt = now
 		log.Println("Scheduler Started.")
 	}
 	return _scheduler
@@ -104,7 +92,13 @@ func (s *SchedulerService) tick(t time.Time) {
 
 	removal := []*ScheduledFunction{}
 
-	for _, fn := range s.funcs {
+	// functions can schedule more functions, so work off a copy
+	s.Lock()
+	funcs := make([]*ScheduledFunction, len(s.funcs))
+	copy(funcs, s.funcs)
+	s.Unlock()
+
+	for _, fn := range funcs {
 		fn.Current++
 		if fn.Current == fn.Seconds {
 			fn.Func()
@@ -200,7 +200,7 @@ func (s *StarSystemData) GetData() *StarSystemData {
 func do_starsystems(entity Entity, args ...string) {
 	db := DB()
 	entity.Send("\r\n")
-	entity.Send(MakeTitle("Star Systems", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER))
+	entity.Send(MakeTitle("Star Systems", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
 
 	for _, s := range db.starsystems {
 		starsystem := s.GetData()
@@ -271,10 +271,7 @@ func do_leave_ship(entity Entity, args ...string) {
 		room.SendToOthers(entity, sprintf("\r\n%s has left the ship.\r\n", ch.Name))
 		to_room.SendToOthers(entity, sprintf("\r\n%s has arrived.\r\n", ch.Name))
 		entity.Send("\r\nYou leave the ship.")
-		ServerQueue <- MudClientCommand{
-			Entity:  entity,
-			Command: "look",
-		}
+		queue_command(entity, "look")
 	}
 }
 
@@ -0,0 +1,447 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	SSH_HOST_KEY          = "data/sys/ssh_host_ed25519_key"
+	SSH_HANDSHAKE_TIMEOUT = 30 * time.Second // to get through the handshake and the password
+)
+
+// SSHClient adapts an ssh session channel to [Client]. With a pty the client
+// sends us raw keystrokes, so we do the line editing (and the echo) ourselves.
+type SSHClient struct {
+	Id      string
+	Con     ssh.Channel
+	reader  *bufio.Reader
+	wm      *sync.Mutex
+	echo    bool
+	pty     bool
+	Term    string
+	addr    string
+	width   int32
+	height  int32
+	caps    uint32
+	input   *InputQueue
+	closed  int32 // atomic, the game loop and the connection both look
+	idle    int32
+	editing int32
+	EditPtr *string
+	Queue   []string
+}
+
+func NewSSHClient(con ssh.Channel, remote net.Addr) *SSHClient {
+	client := new(SSHClient)
+	client.Id = hex.EncodeToString([]byte("ssh:" + remote.String()))
+	client.Con = con
+	client.addr = addr_host(remote)
+	client.reader = bufio.NewReader(con)
+	client.wm = &sync.Mutex{}
+	client.echo = true
+	client.width = TERM_WIDTH_DEFAULT
+	client.height = 24
+	client.caps = uint32(TERM_CAPS_DEFAULT)
+	client.input = NewInputQueue()
+	return client
+}
+
+func (c *SSHClient) write(buffer []byte) {
+	c.wm.Lock()
+	_, err := c.Con.Write(buffer)
+	c.wm.Unlock()
+	if err != nil {
+		c.Close()
+	}
+}
+
+func (c *SSHClient) Send(str string) {
+	str = Color().Render(str, c.GetCaps())
+	if c.IsEditing() {
+		c.Queue = append(c.Queue, str)
+	} else {
+		c.write([]byte(str))
+	}
+}
+
+func (c *SSHClient) Sendf(format string, any ...interface{}) {
+	c.Send(fmt.Sprintf(format, any...))
+}
+
+func (c *SSHClient) Raw(buffer []byte) {
+	c.write(buffer)
+}
+
+func (c *SSHClient) Flush() {}
+
+func (c *SSHClient) ReadRaw(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// Read is a tiny line discipline: echo, backspace, enter. Escape sequences
+// (arrow keys and such) are thrown away.
+func (c *SSHClient) Read() string {
+	buf := make([]rune, 0, 64)
+	esc := 0
+	for {
+		if c.IsClosed() || c.IsEditing() {
+			return ""
+		}
+		r, _, err := c.reader.ReadRune()
+		if err != nil {
+			c.Close()
+			return ""
+		}
+		if esc > 0 {
+			// ESC [ ... final byte
+			if esc == 1 && r != '[' && r != 'O' {
+				esc = 0
+			} else if esc > 1 && r >= 0x40 && r <= 0x7e {
+				esc = 0
+			} else {
+				esc++
+			}
+			continue
+		}
+		switch r {
+		case '\r', '\n':
+			if !c.pty && r == '\r' {
+				// no pty means a dumb pipe, CR LF will follow
+				continue
+			}
+			if c.pty {
+				c.write([]byte("\r\n"))
+			}
+			return strings.TrimSpace(string(buf))
+		case 0x7f, 0x08:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+				if c.pty && c.echo {
+					c.write([]byte("\b \b"))
+				}
+			}
+		case 0x03, 0x04:
+			// ^C / ^D, treat it like hanging up
+			c.Close()
+			return ""
+		case 0x1b:
+			esc = 1
+		default:
+			if r < 0x20 {
+				continue
+			}
+			buf = append(buf, r)
+			if c.pty && c.echo {
+				c.write([]byte(string(r)))
+			}
+		}
+	}
+}
+
+func (c *SSHClient) Close() {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return
+	}
+	c.Con.Close()
+}
+
+func (c *SSHClient) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func (c *SSHClient) BufferEditor(str *string) {
+	if !c.IsEditing() {
+		c.EditPtr = str
+		c.SetEditing(true)
+	}
+}
+
+func (c *SSHClient) GetId() string {
+	return c.Id
+}
+
+func (c *SSHClient) SetEditing(editing bool) {
+	atomic.StoreInt32(&c.editing, bool_int32(editing))
+}
+
+func (c *SSHClient) IsEditing() bool {
+	return atomic.LoadInt32(&c.editing) == 1
+}
+
+func (c *SSHClient) IdleInc() {
+	atomic.AddInt32(&c.idle, 1)
+}
+
+func (c *SSHClient) GetIdle() int {
+	return int(atomic.LoadInt32(&c.idle))
+}
+
+func (c *SSHClient) IdleReset() {
+	atomic.StoreInt32(&c.idle, 0)
+}
+
+func (c *SSHClient) SendQueue() {
+	for _, s := range c.Queue {
+		c.write([]byte(s))
+	}
+}
+
+func (c *SSHClient) ClearQueue() {
+	c.Queue = make([]string, 0)
+}
+
+func (c *SSHClient) resize(width int, height int) {
+	atomic.StoreInt32(&c.width, int32(width))
+	atomic.StoreInt32(&c.height, int32(height))
+}
+
+func (c *SSHClient) GetWidth() int {
+	return int(atomic.LoadInt32(&c.width))
+}
+
+func (c *SSHClient) GetCaps() TermCaps {
+	return TermCaps(atomic.LoadUint32(&c.caps))
+}
+
+func (c *SSHClient) GetAddr() string {
+	return c.addr
+}
+
+func (c *SSHClient) Input() *InputQueue {
+	return c.input
+}
+
+func (c *SSHClient) Telnet() *Telnet {
+	return nil
+}
+
+func (c *SSHClient) SendGMCP(pkg string, data interface{}) {
+}
+
+func (c *SSHClient) SetEcho(echo bool) {
+	c.echo = echo
+}
+
+// Loads the host key, or makes one the first time we boot.
+func ssh_host_key() (ssh.Signer, error) {
+	if !file_exists(SSH_HOST_KEY) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		buf := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		if err := write_file_atomic(SSH_HOST_KEY, buf, 0600); err != nil {
+			return nil, err
+		}
+		Log(LOG_NET).Info("generated a new ssh host key", "file", SSH_HOST_KEY)
+	}
+	buf, err := os.ReadFile(SSH_HOST_KEY)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(buf)
+}
+
+// ssh user@host with a known account and the right password skips the login
+// prompts and goes straight to the character menu. Anything else (unknown
+// user, wrong password, locked out) gets the normal login flow like telnet
+// does, all the same way so there's no telling which usernames exist.
+func ssh_password_callback(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	account := account_find(meta.User())
+	if account == nil {
+		return &ssh.Permissions{}, nil
+	}
+	host := addr_host(meta.RemoteAddr())
+	if wait, _ := Bans().LoginWait(host, account.Username); wait > 0 {
+		return &ssh.Permissions{}, nil
+	}
+	if !account.CheckPassword(string(password)) {
+		Bans().LoginFailed(host, account.Username)
+		return &ssh.Permissions{}, nil
+	}
+	Bans().LoginOk(host, account.Username)
+	return &ssh.Permissions{Extensions: map[string]string{"account": strconv.Itoa(int(account.ID))}}, nil
+}
+
+// Clients that would rather be asked get asked for the password, and it's
+// checked the same as above.
+func ssh_keyboard_callback(meta ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	answers, err := challenge(meta.User(), "", []string{"Password: "}, []bool{false})
+	if err != nil {
+		return nil, err
+	}
+	if len(answers) != 1 {
+		return &ssh.Permissions{}, nil
+	}
+	return ssh_password_callback(meta, []byte(answers[0]))
+}
+
+func SSHServerStart(addr string) {
+	key, err := ssh_host_key()
+	if err != nil {
+		Log(LOG_NET).Error("can't load the ssh host key, ssh is disabled", "err", err)
+		return
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback:            ssh_password_callback,
+		KeyboardInteractiveCallback: ssh_keyboard_callback,
+		ServerVersion:               "SSH-2.0-SWR",
+	}
+	config.AddHostKey(key)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		ErrorCheck(err)
+		return
+	}
+	defer l.Close()
+	Log(LOG_NET).Info("listening", "addr", addr, "via", "ssh")
+	for {
+		if !ServerRunning {
+			break
+		}
+		c, err := l.Accept()
+		if err != nil {
+			Log(LOG_NET).Warn("error accepting a connection", "err", err)
+			continue
+		}
+		go ssh_accept(c, config)
+	}
+}
+
+func ssh_accept(con net.Conn, config *ssh.ServerConfig) {
+	if ban := Bans().Site(addr_host(con.RemoteAddr())); ban != nil {
+		Log(LOG_NET).Wiz(WIZ_BANS).Info("refused connection from banned site", "addr", addr_host(con.RemoteAddr()), "ban", ban.Target, "via", "ssh")
+		con.Close()
+		return
+	}
+	// somebody who connects and says nothing doesn't get to keep a goroutine
+	con.SetDeadline(time.Now().Add(SSH_HANDSHAKE_TIMEOUT))
+	sc, chans, reqs, err := ssh.NewServerConn(con, config)
+	if err != nil {
+		Log(LOG_NET).Info("ssh handshake failed", "addr", addr_host(con.RemoteAddr()), "err", err)
+		con.Close()
+		return
+	}
+	con.SetDeadline(time.Time{})
+	Log(LOG_NET).Info("connection accepted", "addr", addr_host(sc.RemoteAddr()), "via", "ssh")
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		client := NewSSHClient(ch, sc.RemoteAddr())
+		go ssh_session(client, sc.Permissions, requests)
+	}
+}
+
+// Handles the session requests. The game starts on "shell" and the pty ones
+// keep the window size up to date.
+func ssh_session(client *SSHClient, perms *ssh.Permissions, requests <-chan *ssh.Request) {
+	started := false
+	for req := range requests {
+		ok := false
+		switch req.Type {
+		case "pty-req":
+			client.pty = true
+			term, rest := ssh_string(req.Payload)
+			client.Term = term
+			atomic.StoreUint32(&client.caps, uint32(ttype_caps(term)))
+			if len(rest) >= 8 {
+				client.resize(ssh_window(rest))
+			}
+			ok = true
+		case "window-change":
+			if len(req.Payload) >= 8 {
+				client.resize(ssh_window(req.Payload))
+			}
+		case "env":
+			ok = true
+		case "shell":
+			ok = !started
+			if !started {
+				started = true
+				go func() {
+					client_session(client, func(c Client) {
+						var account *Account
+						if perms != nil && perms.Extensions["account"] != "" {
+							id, _ := strconv.Atoi(perms.Extensions["account"])
+							account = account_get(uint(id))
+						}
+						if account != nil {
+							auth_do_menu(c, account)
+						} else {
+							auth_do_welcome(c)
+						}
+					})
+					client.Con.CloseWrite()
+					client.Close()
+				}()
+			}
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+func ssh_string(payload []byte) (string, []byte) {
+	if len(payload) < 4 {
+		return "", payload
+	}
+	n := binary.BigEndian.Uint32(payload)
+	if uint32(len(payload)-4) < n {
+		return "", payload
+	}
+	return string(payload[4 : 4+n]), payload[4+n:]
+}
+
+func ssh_window(payload []byte) (int, int) {
+	w := int(binary.BigEndian.Uint32(payload))
+	h := int(binary.BigEndian.Uint32(payload[4:]))
+	if h <= 0 {
+		h = 24
+	}
+	return term_width(w), h
+}
@@ -0,0 +1,237 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Where the things players change end up: the players themselves (with the
+// items they carry), ships, what they left lying around the world (see
+// [WorldState]) and mail waiting in the outbox. The world that
+// builders write (areas, mob and item templates, ship prototypes, planets)
+// is YAML whatever the storage is, so it can still be edited by hand and
+// kept in git.
+//
+// `storage` in config.yml picks yaml (the default, a file per player under
+// data/accounts) or sqlite (tables in data/game.db next to the accounts).
+// `swr convert yaml sqlite` moves everything from one to the other.
+
+const (
+	STORAGE_YAML   = "yaml"
+	STORAGE_SQLITE = "sqlite"
+)
+
+type Database interface {
+	Name() string
+	HasPlayer(name string) bool
+	LoadPlayer(name string) (*PlayerProfile, error)
+	SavePlayer(player *PlayerProfile) error
+	Players() ([]string, error) // names of every player stored
+	LoadShips() ([]*ShipData, error)
+	SaveShip(ship *ShipData) error
+	DeleteShip(name string) error
+	LoadWorld() (*WorldState, error) // nil if it was never saved
+	SaveWorld(world *WorldState) error
+	SaveMail(mail *MailData) error
+	Outbox() ([]*MailData, error) // mail that was never sent, oldest first
+}
+
+// A mail in the outbox, when there's no smtp server to send it through.
+type MailData struct {
+	ID      uint `gorm:"primarykey"`
+	Sent    time.Time
+	To      string
+	Subject string
+	Message string // headers and all, ready to go
+}
+
+var _storage Database
+
+func Storage() Database {
+	if _storage == nil {
+		s, err := storage_open(Config().Storage)
+		if err != nil {
+			Log(LOG_DB).Error("can't open storage, refusing to start", "storage", Config().Storage, "err", err)
+			os.Exit(1)
+		}
+		_storage = s
+		Log(LOG_DB).Info("storage opened", "storage", s.Name())
+	}
+	return _storage
+}
+
+func storage_open(kind string) (Database, error) {
+	switch strings.ToLower(kind) {
+	case "", STORAGE_YAML:
+		return &YAMLDatabase{}, nil
+	case STORAGE_SQLITE:
+		return sql_database_open(DB().db)
+	}
+	return nil, Err("unknown storage %s, it's %s or %s", kind, STORAGE_YAML, STORAGE_SQLITE)
+}
+
+// data/ships/<name>.yml, and the key a ship is stored under.
+func ship_key(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", "_"))
+}
+
+// Copies everything from one storage to the other and reads it back to make
+// sure it all made it. Returns what the process should exit with.
+func Convert(from string, to string) int {
+	if strings.EqualFold(from, to) {
+		fmt.Printf("Nothing to do, %s is %s.\n", from, to)
+		return 1
+	}
+	src, err := storage_open(from)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	dst, err := storage_open(to)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	bad := 0
+	problem := func(what string, err error) {
+		bad++
+		fmt.Printf("%s: %v\n", what, err)
+	}
+
+	names, err := src.Players()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for _, name := range names {
+		player, err := src.LoadPlayer(name)
+		if err != nil {
+			problem("player "+name, err)
+			continue
+		}
+		if err := dst.SavePlayer(player); err != nil {
+			problem("player "+name, err)
+			continue
+		}
+		back, err := dst.LoadPlayer(name)
+		if err != nil {
+			problem("player "+name, err)
+			continue
+		}
+		if !convert_same(player, back) {
+			problem("player "+name, Err("isn't the same read back from %s", to))
+		}
+	}
+
+	ships, err := src.LoadShips()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	saved := make(map[string]*ShipData)
+	for _, ship := range ships {
+		if err := dst.SaveShip(ship); err != nil {
+			problem("ship "+ship.Name, err)
+			continue
+		}
+		saved[ship_key(ship.Name)] = ship
+	}
+	back, err := dst.LoadShips()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for _, ship := range back {
+		if orig, ok := saved[ship_key(ship.Name)]; ok && !convert_same(orig, ship) {
+			problem("ship "+ship.Name, Err("isn't the same read back from %s", to))
+		}
+	}
+
+	world, err := src.LoadWorld()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	rooms := 0
+	if world != nil {
+		rooms = len(world.Rooms)
+		if err := dst.SaveWorld(world); err != nil {
+			problem("world", err)
+		} else if back, err := dst.LoadWorld(); err != nil {
+			problem("world", err)
+		} else if !convert_same(world, back) {
+			problem("world", Err("isn't the same read back from %s", to))
+		}
+	}
+
+	outbox, err := src.Outbox()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	there, err := dst.Outbox()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	// so running it twice doesn't send everything twice
+	have := make(map[string]bool)
+	for _, m := range there {
+		have[mail_key(m)] = true
+	}
+	mails := 0
+	for _, m := range outbox {
+		if have[mail_key(m)] {
+			continue
+		}
+		if err := dst.SaveMail(&MailData{Sent: m.Sent, To: m.To, Subject: m.Subject, Message: m.Message}); err != nil {
+			problem("mail to "+m.To, err)
+			continue
+		}
+		mails++
+	}
+
+	fmt.Printf("%d players, %d ships, %d rooms and %d mails copied from %s to %s, %d problems.\n", len(names), len(ships), rooms, mails, src.Name(), dst.Name(), bad)
+	if bad > 0 {
+		return 1
+	}
+	fmt.Printf("Set storage: %s in data/sys/config.yml to use it.\n", dst.Name())
+	return 0
+}
+
+// Same on disk is the same.
+func convert_same(a interface{}, b interface{}) bool {
+	x, err := marshal_yaml(a)
+	if err != nil {
+		return false
+	}
+	y, err := marshal_yaml(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
+
+func mail_key(m *MailData) string {
+	return fmt.Sprintf("%d %s %s", m.Sent.UnixNano(), m.To, m.Message)
+}
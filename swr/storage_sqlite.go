@@ -0,0 +1,427 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Players, ships and the outbox in data/game.db. A row keeps the same YAML
+// (schema header and all) the file would have had, so migrations work the
+// same, except for items: every item instance is a row of its own, owned by
+// a player, ship or room, with containers pointing at what they're in.
+type SQLDatabase struct {
+	db *gorm.DB
+}
+
+type PlayerRecord struct {
+	gorm.Model
+	Name string `gorm:"index:idx_player_record_name,unique"`
+	Data string
+}
+
+type ShipRecord struct {
+	gorm.Model
+	Name string `gorm:"index:idx_ship_record_name,unique"`
+	Data string
+}
+
+// The one and only world state.
+type WorldRecord struct {
+	gorm.Model
+	Data string
+}
+
+type ItemRecord struct {
+	ID     uint   `gorm:"primarykey"`
+	Owner  string `gorm:"index"` // player:<name>, ship:<name>, room:<id>
+	Slot   string // the wear location, high or low for ships, empty when carried
+	Parent uint   // the container it's in, 0 if it isn't
+	Data   string
+}
+
+// An item and where its owner keeps it.
+type item_slot struct {
+	slot string
+	item *ItemData
+}
+
+func sql_database_open(db *gorm.DB) (*SQLDatabase, error) {
+	if err := db.AutoMigrate(&PlayerRecord{}, &ShipRecord{}, &WorldRecord{}, &ItemRecord{}, &MailData{}); err != nil {
+		return nil, err
+	}
+	return &SQLDatabase{db: db}, nil
+}
+
+func (*SQLDatabase) Name() string {
+	return STORAGE_SQLITE
+}
+
+func (s *SQLDatabase) HasPlayer(name string) bool {
+	var count int64
+	s.db.Model(&PlayerRecord{}).Where("name = ?", strings.ToLower(name)).Count(&count)
+	return count > 0
+}
+
+func (s *SQLDatabase) LoadPlayer(name string) (*PlayerProfile, error) {
+	name = strings.ToLower(name)
+	rec := new(PlayerRecord)
+	if err := s.db.Where("name = ?", name).First(rec).Error; err != nil {
+		return nil, err
+	}
+	player := new(PlayerProfile)
+	if err := load_yaml_bytes("player "+name, []byte(rec.Data), player); err != nil {
+		return nil, err
+	}
+	items, err := sql_load_items(s.db, "player:"+name)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range items {
+		if i.slot == "" {
+			player.Char.Inventory = append(player.Char.Inventory, i.item)
+			continue
+		}
+		if player.Char.Equipment == nil {
+			player.Char.Equipment = make(map[string]*ItemData)
+		}
+		player.Char.Equipment[i.slot] = i.item
+	}
+	return player, nil
+}
+
+func (s *SQLDatabase) SavePlayer(player *PlayerProfile) error {
+	name := strings.ToLower(player.Char.Name)
+	// the items get rows of their own
+	p := *player
+	p.Char.Equipment = nil
+	p.Char.Inventory = nil
+	buf, err := marshal_yaml(&p)
+	if err != nil {
+		return err
+	}
+	items := make([]item_slot, 0, len(player.Char.Equipment)+len(player.Char.Inventory))
+	locs := make([]string, 0, len(player.Char.Equipment))
+	for loc := range player.Char.Equipment {
+		locs = append(locs, loc)
+	}
+	sort.Strings(locs)
+	for _, loc := range locs {
+		items = append(items, item_slot{slot: loc, item: player.Char.Equipment[loc]})
+	}
+	for _, item := range player.Char.Inventory {
+		items = append(items, item_slot{item: item})
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		rec := new(PlayerRecord)
+		if err := tx.Where("name = ?", name).Limit(1).Find(rec).Error; err != nil {
+			return err
+		}
+		rec.Name = name
+		rec.Data = string(buf)
+		if err := tx.Save(rec).Error; err != nil {
+			return err
+		}
+		return sql_save_items(tx, "player:"+name, items)
+	})
+}
+
+func (s *SQLDatabase) Players() ([]string, error) {
+	ret := make([]string, 0)
+	err := s.db.Model(&PlayerRecord{}).Order("name").Pluck("name", &ret).Error
+	return ret, err
+}
+
+func (s *SQLDatabase) LoadShips() ([]*ShipData, error) {
+	recs := make([]*ShipRecord, 0)
+	if err := s.db.Order("id").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	ret := make([]*ShipData, 0, len(recs))
+	for _, rec := range recs {
+		ship := new(ShipData)
+		if err := load_yaml_bytes("ship "+rec.Name, []byte(rec.Data), ship); err != nil {
+			load_problem(err)
+			continue
+		}
+		items, err := sql_load_items(s.db, "ship:"+rec.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range items {
+			if i.slot == "high" {
+				ship.HighSlots = append(ship.HighSlots, i.item)
+			} else {
+				ship.LowSlots = append(ship.LowSlots, i.item)
+			}
+		}
+		ret = append(ret, ship)
+	}
+	return ret, nil
+}
+
+func (s *SQLDatabase) SaveShip(ship *ShipData) error {
+	name := ship_key(ship.Name)
+	c := *ship
+	c.HighSlots = nil
+	c.LowSlots = nil
+	buf, err := marshal_yaml(&c)
+	if err != nil {
+		return err
+	}
+	items := make([]item_slot, 0, len(ship.HighSlots)+len(ship.LowSlots))
+	for _, item := range ship.HighSlots {
+		items = append(items, item_slot{slot: "high", item: item})
+	}
+	for _, item := range ship.LowSlots {
+		items = append(items, item_slot{slot: "low", item: item})
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		rec := new(ShipRecord)
+		if err := tx.Where("name = ?", name).Limit(1).Find(rec).Error; err != nil {
+			return err
+		}
+		rec.Name = name
+		rec.Data = string(buf)
+		if err := tx.Save(rec).Error; err != nil {
+			return err
+		}
+		return sql_save_items(tx, "ship:"+name, items)
+	})
+}
+
+// The ship and everything fitted to it.
+func (s *SQLDatabase) DeleteShip(name string) error {
+	name = ship_key(name)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("name = ?", name).Delete(&ShipRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("owner = ?", "ship:"+name).Delete(&ItemRecord{}).Error
+	})
+}
+
+func (s *SQLDatabase) LoadWorld() (*WorldState, error) {
+	rec := new(WorldRecord)
+	res := s.db.Order("id").Limit(1).Find(rec)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	world := new(WorldState)
+	if err := load_yaml_bytes("world", []byte(rec.Data), world); err != nil {
+		return nil, err
+	}
+	for _, rs := range world.Rooms {
+		items, err := sql_load_items(s.db, sprintf("room:%d", rs.Room))
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range items {
+			rs.Items = append(rs.Items, i.item)
+		}
+	}
+	return world, nil
+}
+
+func (s *SQLDatabase) SaveWorld(world *WorldState) error {
+	c := *world
+	c.Rooms = make([]*RoomState, 0, len(world.Rooms))
+	for _, rs := range world.Rooms {
+		r := *rs
+		r.Items = nil
+		c.Rooms = append(c.Rooms, &r)
+	}
+	buf, err := marshal_yaml(&c)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		rec := new(WorldRecord)
+		if err := tx.Order("id").Limit(1).Find(rec).Error; err != nil {
+			return err
+		}
+		rec.Data = string(buf)
+		if err := tx.Save(rec).Error; err != nil {
+			return err
+		}
+		// most rooms look the same as last time, only write the ones that don't
+		recs := make([]*ItemRecord, 0)
+		if err := tx.Where("owner LIKE ?", "room:%").Order("id").Find(&recs).Error; err != nil {
+			return err
+		}
+		saved := make(map[string][]*ItemRecord)
+		for _, rec := range recs {
+			saved[rec.Owner] = append(saved[rec.Owner], rec)
+		}
+		for _, rs := range world.Rooms {
+			owner := sprintf("room:%d", rs.Room)
+			items := make([]item_slot, 0, len(rs.Items))
+			for _, item := range rs.Items {
+				items = append(items, item_slot{item: item})
+			}
+			rows, err := sql_item_rows(items)
+			if err != nil {
+				return err
+			}
+			was := saved[owner]
+			delete(saved, owner)
+			if sql_same_rows(was, rows) {
+				continue
+			}
+			if err := sql_save_items(tx, owner, items); err != nil {
+				return err
+			}
+		}
+		// rooms that aren't in the world any more
+		for owner := range saved {
+			if err := tx.Where("owner = ?", owner).Delete(&ItemRecord{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLDatabase) SaveMail(mail *MailData) error {
+	return s.db.Create(mail).Error
+}
+
+func (s *SQLDatabase) Outbox() ([]*MailData, error) {
+	ret := make([]*MailData, 0)
+	err := s.db.Order("sent, id").Find(&ret).Error
+	return ret, err
+}
+
+// Replaces everything owner has with items, containers and all.
+func sql_save_items(tx *gorm.DB, owner string, items []item_slot) error {
+	if err := tx.Where("owner = ?", owner).Delete(&ItemRecord{}).Error; err != nil {
+		return err
+	}
+	for _, i := range items {
+		if err := sql_save_item(tx, owner, i.slot, 0, i.item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sql_save_item(tx *gorm.DB, owner string, slot string, parent uint, item *ItemData) error {
+	if item == nil {
+		return nil
+	}
+	c := *item
+	c.Items = nil
+	buf, err := marshal_yaml(&c)
+	if err != nil {
+		return err
+	}
+	rec := &ItemRecord{Owner: owner, Slot: slot, Parent: parent, Data: string(buf)}
+	if err := tx.Create(rec).Error; err != nil {
+		return err
+	}
+	for _, child := range item.Items {
+		if err := sql_save_item(tx, owner, "", rec.ID, child.GetData()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The rows sql_save_items would write for items, in the same order, with
+// Parent counting from 1 into the list instead of an id.
+func sql_item_rows(items []item_slot) ([]*ItemRecord, error) {
+	rows := make([]*ItemRecord, 0, len(items))
+	var add func(slot string, parent uint, item *ItemData) error
+	add = func(slot string, parent uint, item *ItemData) error {
+		if item == nil {
+			return nil
+		}
+		c := *item
+		c.Items = nil
+		buf, err := marshal_yaml(&c)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, &ItemRecord{Slot: slot, Parent: parent, Data: string(buf)})
+		at := uint(len(rows))
+		for _, child := range item.Items {
+			if err := add("", at, child.GetData()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, i := range items {
+		if err := add(i.slot, 0, i.item); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// Whether the saved rows (real ids) hold the same as rows from sql_item_rows.
+func sql_same_rows(saved []*ItemRecord, rows []*ItemRecord) bool {
+	if len(saved) != len(rows) {
+		return false
+	}
+	at := make(map[uint]uint, len(saved))
+	for i, rec := range saved {
+		at[rec.ID] = uint(i + 1)
+	}
+	for i, rec := range saved {
+		parent := uint(0)
+		if rec.Parent != 0 {
+			parent = at[rec.Parent]
+		}
+		if rec.Slot != rows[i].Slot || parent != rows[i].Parent || rec.Data != rows[i].Data {
+			return false
+		}
+	}
+	return true
+}
+
+// Everything owner has, in the order it was saved, with the containers
+// filled back in.
+func sql_load_items(db *gorm.DB, owner string) ([]item_slot, error) {
+	recs := make([]*ItemRecord, 0)
+	if err := db.Where("owner = ?", owner).Order("id").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	ret := make([]item_slot, 0, len(recs))
+	loaded := make(map[uint]*ItemData)
+	for _, rec := range recs {
+		item := new(ItemData)
+		if err := load_yaml_bytes(sprintf("%s item %d", owner, rec.ID), []byte(rec.Data), item); err != nil {
+			return nil, err
+		}
+		loaded[rec.ID] = item
+		if rec.Parent != 0 {
+			// containers are saved before what's in them
+			if parent, ok := loaded[rec.Parent]; ok {
+				parent.Items = append(parent.Items, item)
+				continue
+			}
+			Log(LOG_DB).Warn("item's container is gone, it's loose now", "owner", owner, "item", rec.ID)
+		}
+		ret = append(ret, item_slot{slot: rec.Slot, item: item})
+	}
+	return ret, nil
+}
@@ -0,0 +1,119 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func test_sql_database(t *testing.T) *SQLDatabase {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := sql_database_open(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func test_room_rows(t *testing.T, s *SQLDatabase) map[string][]uint {
+	recs := make([]*ItemRecord, 0)
+	if err := s.db.Where("owner LIKE ?", "room:%").Order("id").Find(&recs).Error; err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string][]uint)
+	for _, rec := range recs {
+		ret[rec.Owner] = append(ret[rec.Owner], rec.ID)
+	}
+	return ret
+}
+
+// Saving the world again only rewrites the rooms whose items changed.
+func TestSQLSaveWorld(t *testing.T) {
+	s := test_sql_database(t)
+	bag := &ItemData{Id: 1, Name: "a bag", Type: "container", Items: []Item{&ItemData{Id: 2, Name: "a rock"}}}
+	world := &WorldState{Rooms: []*RoomState{
+		{Room: 100, Items: []*ItemData{bag}},
+		{Room: 101, Items: []*ItemData{{Id: 3, Name: "a stick"}}},
+		{Room: 102, Items: []*ItemData{{Id: 4, Name: "a leaf"}}},
+	}}
+	if err := s.SaveWorld(world); err != nil {
+		t.Fatal(err)
+	}
+	before := test_room_rows(t, s)
+	if len(before["room:100"]) != 2 || len(before["room:101"]) != 1 || len(before["room:102"]) != 1 {
+		t.Fatalf("saved %v", before)
+	}
+
+	world.Rooms[1].Items[0].Name = "a broken stick"
+	world.Rooms = world.Rooms[:2]
+	if err := s.SaveWorld(world); err != nil {
+		t.Fatal(err)
+	}
+	after := test_room_rows(t, s)
+	if len(after["room:100"]) != 2 || after["room:100"][0] != before["room:100"][0] || after["room:100"][1] != before["room:100"][1] {
+		t.Errorf("room 100 didn't change but was rewritten: %v, was %v", after["room:100"], before["room:100"])
+	}
+	if len(after["room:101"]) != 1 || after["room:101"][0] == before["room:101"][0] {
+		t.Errorf("room 101 changed but wasn't rewritten: %v, was %v", after["room:101"], before["room:101"])
+	}
+	if len(after["room:102"]) != 0 {
+		t.Errorf("room 102 is gone but its items are still there: %v", after["room:102"])
+	}
+
+	loaded, err := s.LoadWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Rooms) != 2 || len(loaded.Rooms[0].Items) != 1 || len(loaded.Rooms[0].Items[0].Items) != 1 {
+		t.Fatalf("loaded %+v", loaded.Rooms)
+	}
+	if name := loaded.Rooms[1].Items[0].Name; name != "a broken stick" {
+		t.Errorf("room 101 has %s, wanted a broken stick", name)
+	}
+}
+
+func TestSQLSavePlayer(t *testing.T) {
+	s := test_sql_database(t)
+	player := new(PlayerProfile)
+	player.Char.Name = "Luke"
+	player.Char.Inventory = []*ItemData{{Id: 5, Name: "a lightsaber"}}
+	for i := 0; i < 2; i++ {
+		if err := s.SavePlayer(player); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var count int64
+	s.db.Model(&PlayerRecord{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d player rows, wanted 1", count)
+	}
+	loaded, err := s.LoadPlayer("luke")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Char.Inventory) != 1 {
+		t.Errorf("loaded %d items, wanted 1", len(loaded.Char.Inventory))
+	}
+}
@@ -0,0 +1,168 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The storage we've always had, a YAML file for each player and ship, one for
+// the world, and mail dropped in a directory as .eml files.
+type YAMLDatabase struct{}
+
+func (*YAMLDatabase) Name() string {
+	return STORAGE_YAML
+}
+
+func (*YAMLDatabase) HasPlayer(name string) bool {
+	return file_exists(player_path(name))
+}
+
+func (*YAMLDatabase) LoadPlayer(name string) (*PlayerProfile, error) {
+	player := new(PlayerProfile)
+	if err := load_yaml(player_path(name), player); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+func (*YAMLDatabase) SavePlayer(player *PlayerProfile) error {
+	return write_yaml(player_path(player.Char.Name), player)
+}
+
+func (*YAMLDatabase) Players() ([]string, error) {
+	files, err := filepath.Glob("data/accounts/*/*.yml")
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(files))
+	for _, file := range files {
+		ret = append(ret, strings.TrimSuffix(filepath.Base(file), ".yml"))
+	}
+	return ret, nil
+}
+
+// Everything in data/ships but the prototypes.
+func (*YAMLDatabase) LoadShips() ([]*ShipData, error) {
+	ret := make([]*ShipData, 0)
+	err := filepath.Walk("data/ships", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if strings.Contains(path, "prototype") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml") {
+			return nil
+		}
+		ship := new(ShipData)
+		if err := load_yaml(path, ship); err != nil {
+			load_problem(err)
+			return nil
+		}
+		ship.Filename = path
+		ret = append(ret, ship)
+		return nil
+	})
+	return ret, err
+}
+
+func (*YAMLDatabase) SaveShip(ship *ShipData) error {
+	return write_yaml(sprintf("data/ships/%s.yml", ship_key(ship.Name)), ship)
+}
+
+func (*YAMLDatabase) DeleteShip(name string) error {
+	return os.Remove(sprintf("data/ships/%s.yml", ship_key(name)))
+}
+
+const WORLD_FILE = "data/world.yml"
+
+func (*YAMLDatabase) LoadWorld() (*WorldState, error) {
+	if !file_exists(WORLD_FILE) {
+		return nil, nil
+	}
+	world := new(WorldState)
+	if err := load_yaml(WORLD_FILE, world); err != nil {
+		return nil, err
+	}
+	return world, nil
+}
+
+func (*YAMLDatabase) SaveWorld(world *WorldState) error {
+	return write_yaml(WORLD_FILE, world)
+}
+
+func mail_dir() string {
+	if Config().Mail.Dir != "" {
+		return Config().Mail.Dir
+	}
+	return "data/mail"
+}
+
+// <when it was sent>-<who to>.eml
+func (*YAMLDatabase) SaveMail(mail *MailData) error {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, mail.To)
+	path := filepath.Join(mail_dir(), fmt.Sprintf("%d-%s.eml", mail.Sent.UnixNano(), name))
+	return write_file_atomic(path, []byte(mail.Message), 0644)
+}
+
+func (*YAMLDatabase) Outbox() ([]*MailData, error) {
+	files, err := filepath.Glob(filepath.Join(mail_dir(), "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*MailData, 0, len(files))
+	for _, file := range files {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		mail := &MailData{Message: string(buf)}
+		stamp, _, _ := strings.Cut(filepath.Base(file), "-")
+		if n, err := strconv.ParseInt(stamp, 10, 64); err == nil {
+			mail.Sent = time.Unix(0, n)
+		}
+		head, _, _ := strings.Cut(mail.Message, "\r\n\r\n")
+		for _, line := range strings.Split(head, "\r\n") {
+			if strings.HasPrefix(line, "To: ") {
+				mail.To = strings.TrimPrefix(line, "To: ")
+			} else if strings.HasPrefix(line, "Subject: ") {
+				mail.Subject = strings.TrimPrefix(line, "Subject: ")
+			}
+		}
+		ret = append(ret, mail)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Sent.Before(ret[j].Sent)
+	})
+	return ret, nil
+}
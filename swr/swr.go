@@ -41,11 +41,15 @@ func Init() {
 }
 
 func Main() {
+	LogInit()
 	log.Printf("Starting version %s\n", version)
 	assert(is_skill("martial-arts"))
 	DB().Load()
+	accounts_migrate()
+	Bans()
 	defer DB().Save()
 	DB().ResetAll()
+	DB().RestoreWorld()
 	CommandsLoad()
 	LanguageLoad()
 	StartBackup()
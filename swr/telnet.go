@@ -0,0 +1,467 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"sync"
+)
+
+// RFC 1143 "Q method" option states.
+const (
+	TELNET_Q_NO      = 0
+	TELNET_Q_YES     = 1
+	TELNET_Q_WANTNO  = 2
+	TELNET_Q_WANTYES = 3
+)
+
+// input parser states
+const (
+	telnet_state_data = iota
+	telnet_state_iac
+	telnet_state_will
+	telnet_state_wont
+	telnet_state_do
+	telnet_state_dont
+	telnet_state_sb_opt
+	telnet_state_sb
+	telnet_state_sb_iac
+)
+
+// Subnegotiations larger than this are garbage (or an attack), drop them.
+const TELNET_SB_MAX = 8192
+
+// TelnetOption is the per-option Q method state, one side for us (WILL/WONT)
+// and one side for him (DO/DONT). The queue flags are the "OPPOSITE" bit.
+type TelnetOption struct {
+	Us   int
+	UsQ  bool
+	Him  int
+	HimQ bool
+}
+
+// TelnetHandler is how the rest of the mud hooks into an option. Local is true
+// when the option changed on our side (we WILL), false when it's the client's.
+type TelnetHandler struct {
+	OnEnable  func(local bool)
+	OnDisable func(local bool)
+	OnSub     func(data []byte)
+}
+
+// Telnet is the option negotiation layer that sits between the socket and the
+// line reader. Feed it every byte that comes off the wire and it hands back the
+// ones that are actually player input.
+type Telnet struct {
+	m        *sync.Mutex
+	options  [256]TelnetOption
+	local    [256]bool // options we are willing to enable on our side
+	remote   [256]bool // options we will let the client enable on its side
+	handlers map[byte]*TelnetHandler
+	write    func(b []byte)
+	state    int
+	sb_opt   byte
+	sb       []byte
+}
+
+func NewTelnet(write func(b []byte)) *Telnet {
+	return &Telnet{
+		m:        &sync.Mutex{},
+		handlers: make(map[byte]*TelnetHandler),
+		write:    write,
+		state:    telnet_state_data,
+		sb:       make([]byte, 0),
+	}
+}
+
+// Support marks an option as acceptable when the client asks for it.
+func (t *Telnet) Support(opt byte, local bool, remote bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.local[opt] = local
+	t.remote[opt] = remote
+}
+
+// Handle registers the hooks for an option, replacing any previous ones.
+func (t *Telnet) Handle(opt byte, handler *TelnetHandler) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.handlers[opt] = handler
+}
+
+// IsLocal reports if the option is enabled on our side.
+func (t *Telnet) IsLocal(opt byte) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.options[opt].Us == TELNET_Q_YES
+}
+
+// IsRemote reports if the option is enabled on the client's side.
+func (t *Telnet) IsRemote(opt byte) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.options[opt].Him == TELNET_Q_YES
+}
+
+// EnableLocal asks to enable an option on our side (IAC WILL opt).
+func (t *Telnet) EnableLocal(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	t.local[opt] = true
+	send := false
+	switch o.Us {
+	case TELNET_Q_NO:
+		o.Us = TELNET_Q_WANTYES
+		send = true
+	case TELNET_Q_WANTNO:
+		o.UsQ = true
+	case TELNET_Q_WANTYES:
+		o.UsQ = false
+	}
+	t.m.Unlock()
+	if send {
+		t.write([]byte{NET_IAC, NET_WILL, opt})
+	}
+}
+
+// DisableLocal asks to disable an option on our side (IAC WONT opt).
+func (t *Telnet) DisableLocal(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	t.local[opt] = false
+	send := false
+	switch o.Us {
+	case TELNET_Q_YES:
+		o.Us = TELNET_Q_WANTNO
+		send = true
+	case TELNET_Q_WANTNO:
+		o.UsQ = false
+	case TELNET_Q_WANTYES:
+		o.UsQ = true
+	}
+	t.m.Unlock()
+	if send {
+		t.write([]byte{NET_IAC, NET_WONT, opt})
+	}
+}
+
+// EnableRemote asks the client to enable an option on its side (IAC DO opt).
+func (t *Telnet) EnableRemote(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	t.remote[opt] = true
+	send := false
+	switch o.Him {
+	case TELNET_Q_NO:
+		o.Him = TELNET_Q_WANTYES
+		send = true
+	case TELNET_Q_WANTNO:
+		o.HimQ = true
+	case TELNET_Q_WANTYES:
+		o.HimQ = false
+	}
+	t.m.Unlock()
+	if send {
+		t.write([]byte{NET_IAC, NET_DO, opt})
+	}
+}
+
+// DisableRemote asks the client to disable an option on its side (IAC DONT opt).
+func (t *Telnet) DisableRemote(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	t.remote[opt] = false
+	send := false
+	switch o.Him {
+	case TELNET_Q_YES:
+		o.Him = TELNET_Q_WANTNO
+		send = true
+	case TELNET_Q_WANTNO:
+		o.HimQ = false
+	case TELNET_Q_WANTYES:
+		o.HimQ = true
+	}
+	t.m.Unlock()
+	if send {
+		t.write([]byte{NET_IAC, NET_DONT, opt})
+	}
+}
+
+// Subnegotiate sends IAC SB opt <data> IAC SE, doubling any IAC in the payload.
+func (t *Telnet) Subnegotiate(opt byte, data []byte) {
+	buf := make([]byte, 0, len(data)+5)
+	buf = append(buf, NET_IAC, NET_SB, opt)
+	for _, b := range data {
+		if b == NET_IAC {
+			buf = append(buf, NET_IAC)
+		}
+		buf = append(buf, b)
+	}
+	buf = append(buf, NET_IAC, NET_SE)
+	t.write(buf)
+}
+
+// Feed pushes one byte from the wire through the parser. If the byte is player
+// input it's returned with ok set, otherwise it was part of a telnet command.
+func (t *Telnet) Feed(b byte) (byte, bool) {
+	switch t.state {
+	case telnet_state_data:
+		if b == NET_IAC {
+			t.state = telnet_state_iac
+			return 0, false
+		}
+		return b, true
+	case telnet_state_iac:
+		t.state = telnet_state_data
+		switch b {
+		case NET_IAC:
+			return b, true // escaped 255
+		case NET_WILL:
+			t.state = telnet_state_will
+		case NET_WONT:
+			t.state = telnet_state_wont
+		case NET_DO:
+			t.state = telnet_state_do
+		case NET_DONT:
+			t.state = telnet_state_dont
+		case NET_SB:
+			t.state = telnet_state_sb_opt
+		}
+		// everything else (NOP, GA, AYT, ...) is swallowed
+		return 0, false
+	case telnet_state_will:
+		t.state = telnet_state_data
+		t.recv_will(b)
+		return 0, false
+	case telnet_state_wont:
+		t.state = telnet_state_data
+		t.recv_wont(b)
+		return 0, false
+	case telnet_state_do:
+		t.state = telnet_state_data
+		t.recv_do(b)
+		return 0, false
+	case telnet_state_dont:
+		t.state = telnet_state_data
+		t.recv_dont(b)
+		return 0, false
+	case telnet_state_sb_opt:
+		t.state = telnet_state_sb
+		t.sb_opt = b
+		t.sb = t.sb[:0]
+		return 0, false
+	case telnet_state_sb:
+		if b == NET_IAC {
+			t.state = telnet_state_sb_iac
+			return 0, false
+		}
+		t.sb_append(b)
+		return 0, false
+	case telnet_state_sb_iac:
+		switch b {
+		case NET_SE:
+			t.state = telnet_state_data
+			t.recv_sb()
+		case NET_IAC:
+			t.state = telnet_state_sb
+			t.sb_append(b)
+		default:
+			// broken client, bail on the subnegotiation and treat it as a command
+			t.state = telnet_state_iac
+			return t.Feed(b)
+		}
+		return 0, false
+	}
+	t.state = telnet_state_data
+	return 0, false
+}
+
+func (t *Telnet) sb_append(b byte) {
+	if len(t.sb) < TELNET_SB_MAX {
+		t.sb = append(t.sb, b)
+	}
+}
+
+func (t *Telnet) handler(opt byte) *TelnetHandler {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.handlers[opt]
+}
+
+func (t *Telnet) enabled(opt byte, local bool) {
+	if h := t.handler(opt); h != nil && h.OnEnable != nil {
+		h.OnEnable(local)
+	}
+}
+
+func (t *Telnet) disabled(opt byte, local bool) {
+	if h := t.handler(opt); h != nil && h.OnDisable != nil {
+		h.OnDisable(local)
+	}
+}
+
+func (t *Telnet) recv_sb() {
+	data := make([]byte, len(t.sb))
+	copy(data, t.sb)
+	if h := t.handler(t.sb_opt); h != nil && h.OnSub != nil {
+		h.OnSub(data)
+	}
+}
+
+func (t *Telnet) recv_will(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	var reply byte
+	on := false
+	switch o.Him {
+	case TELNET_Q_NO:
+		if t.remote[opt] {
+			o.Him = TELNET_Q_YES
+			reply = NET_DO
+			on = true
+		} else {
+			reply = NET_DONT
+		}
+	case TELNET_Q_WANTNO:
+		// DONT answered by WILL is a client bug, we take it as a NO
+		if o.HimQ {
+			o.Him = TELNET_Q_YES
+			o.HimQ = false
+			on = true
+		} else {
+			o.Him = TELNET_Q_NO
+		}
+	case TELNET_Q_WANTYES:
+		if o.HimQ {
+			o.Him = TELNET_Q_WANTNO
+			o.HimQ = false
+			reply = NET_DONT
+		} else {
+			o.Him = TELNET_Q_YES
+			on = true
+		}
+	}
+	t.m.Unlock()
+	if reply != 0 {
+		t.write([]byte{NET_IAC, reply, opt})
+	}
+	if on {
+		t.enabled(opt, false)
+	}
+}
+
+func (t *Telnet) recv_wont(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	var reply byte
+	off := false
+	switch o.Him {
+	case TELNET_Q_YES:
+		o.Him = TELNET_Q_NO
+		reply = NET_DONT
+		off = true
+	case TELNET_Q_WANTNO:
+		if o.HimQ {
+			o.Him = TELNET_Q_WANTYES
+			o.HimQ = false
+			reply = NET_DO
+		} else {
+			o.Him = TELNET_Q_NO
+		}
+	case TELNET_Q_WANTYES:
+		o.Him = TELNET_Q_NO
+		o.HimQ = false
+	}
+	t.m.Unlock()
+	if reply != 0 {
+		t.write([]byte{NET_IAC, reply, opt})
+	}
+	if off {
+		t.disabled(opt, false)
+	}
+}
+
+func (t *Telnet) recv_do(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	var reply byte
+	on := false
+	switch o.Us {
+	case TELNET_Q_NO:
+		if t.local[opt] {
+			o.Us = TELNET_Q_YES
+			reply = NET_WILL
+			on = true
+		} else {
+			reply = NET_WONT
+		}
+	case TELNET_Q_WANTNO:
+		if o.UsQ {
+			o.Us = TELNET_Q_YES
+			o.UsQ = false
+			on = true
+		} else {
+			o.Us = TELNET_Q_NO
+		}
+	case TELNET_Q_WANTYES:
+		if o.UsQ {
+			o.Us = TELNET_Q_WANTNO
+			o.UsQ = false
+			reply = NET_WONT
+		} else {
+			o.Us = TELNET_Q_YES
+			on = true
+		}
+	}
+	t.m.Unlock()
+	if reply != 0 {
+		t.write([]byte{NET_IAC, reply, opt})
+	}
+	if on {
+		t.enabled(opt, true)
+	}
+}
+
+func (t *Telnet) recv_dont(opt byte) {
+	t.m.Lock()
+	o := &t.options[opt]
+	var reply byte
+	off := false
+	switch o.Us {
+	case TELNET_Q_YES:
+		o.Us = TELNET_Q_NO
+		reply = NET_WONT
+		off = true
+	case TELNET_Q_WANTNO:
+		if o.UsQ {
+			o.Us = TELNET_Q_WANTYES
+			o.UsQ = false
+			reply = NET_WILL
+		} else {
+			o.Us = TELNET_Q_NO
+		}
+	case TELNET_Q_WANTYES:
+		o.Us = TELNET_Q_NO
+		o.UsQ = false
+	}
+	t.m.Unlock()
+	if reply != 0 {
+		t.write([]byte{NET_IAC, reply, opt})
+	}
+	if off {
+		t.disabled(opt, true)
+	}
+}
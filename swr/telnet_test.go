@@ -0,0 +1,222 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"bytes"
+	"testing"
+)
+
+// A Telnet that keeps what it sends, and what it got through as input.
+type telnet_test_conn struct {
+	t    *Telnet
+	out  []byte
+	data []byte
+	on   []bool
+	off  []bool
+	subs [][]byte
+}
+
+func new_telnet_test_conn(opt byte, local bool, remote bool) *telnet_test_conn {
+	c := &telnet_test_conn{}
+	c.t = NewTelnet(func(b []byte) { c.out = append(c.out, b...) })
+	c.t.Support(opt, local, remote)
+	c.t.Handle(opt, &TelnetHandler{
+		OnEnable:  func(local bool) { c.on = append(c.on, local) },
+		OnDisable: func(local bool) { c.off = append(c.off, local) },
+		OnSub:     func(data []byte) { c.subs = append(c.subs, data) },
+	})
+	return c
+}
+
+func (c *telnet_test_conn) feed(in []byte) {
+	for _, b := range in {
+		if b, ok := c.t.Feed(b); ok {
+			c.data = append(c.data, b)
+		}
+	}
+}
+
+func telnet_cmd(cmds ...byte) []byte {
+	ret := make([]byte, 0, len(cmds)/2*3)
+	for i := 0; i+1 < len(cmds); i += 2 {
+		ret = append(ret, NET_IAC, cmds[i], cmds[i+1])
+	}
+	return ret
+}
+
+// What the client says and what we should answer, from each starting point.
+func TestTelnetNegotiation(t *testing.T) {
+	const opt = NET_GMCP
+	cases := []struct {
+		name   string
+		local  bool // we'll WILL it
+		remote bool // we'll let him WILL it
+		start  func(*Telnet)
+		in     []byte
+		out    []byte
+		us     int
+		him    int
+		on     int
+		off    int
+	}{
+		{"DO, we will", true, false, nil, telnet_cmd(NET_DO, opt), telnet_cmd(NET_WILL, opt), TELNET_Q_YES, TELNET_Q_NO, 1, 0},
+		{"DO, we won't", false, false, nil, telnet_cmd(NET_DO, opt), telnet_cmd(NET_WONT, opt), TELNET_Q_NO, TELNET_Q_NO, 0, 0},
+		{"DO twice answered once", true, false, nil, telnet_cmd(NET_DO, opt, NET_DO, opt), telnet_cmd(NET_WILL, opt), TELNET_Q_YES, TELNET_Q_NO, 1, 0},
+		{"WILL, we let him", false, true, nil, telnet_cmd(NET_WILL, opt), telnet_cmd(NET_DO, opt), TELNET_Q_NO, TELNET_Q_YES, 1, 0},
+		{"WILL, we don't", false, false, nil, telnet_cmd(NET_WILL, opt), telnet_cmd(NET_DONT, opt), TELNET_Q_NO, TELNET_Q_NO, 0, 0},
+		{"WILL twice answered once", false, true, nil, telnet_cmd(NET_WILL, opt, NET_WILL, opt), telnet_cmd(NET_DO, opt), TELNET_Q_NO, TELNET_Q_YES, 1, 0},
+		{"WONT and DONT when off say nothing", true, true, nil, telnet_cmd(NET_WONT, opt, NET_DONT, opt), nil, TELNET_Q_NO, TELNET_Q_NO, 0, 0},
+		{"our WILL agreed to", true, false, func(t *Telnet) { t.EnableLocal(opt) }, telnet_cmd(NET_DO, opt), nil, TELNET_Q_YES, TELNET_Q_NO, 1, 0},
+		{"our WILL refused", true, false, func(t *Telnet) { t.EnableLocal(opt) }, telnet_cmd(NET_DONT, opt), nil, TELNET_Q_NO, TELNET_Q_NO, 0, 0},
+		{"our DO agreed to", false, true, func(t *Telnet) { t.EnableRemote(opt) }, telnet_cmd(NET_WILL, opt), nil, TELNET_Q_NO, TELNET_Q_YES, 1, 0},
+		{"our DO refused", false, true, func(t *Telnet) { t.EnableRemote(opt) }, telnet_cmd(NET_WONT, opt), nil, TELNET_Q_NO, TELNET_Q_NO, 0, 0},
+		{"changed our mind while asking", true, false, func(t *Telnet) { t.EnableLocal(opt); t.DisableLocal(opt) },
+			telnet_cmd(NET_DO, opt, NET_DONT, opt), telnet_cmd(NET_WONT, opt), TELNET_Q_NO, TELNET_Q_NO, 0, 0},
+		{"turned off by him", true, false, func(t *Telnet) { t.EnableLocal(opt) },
+			telnet_cmd(NET_DO, opt, NET_DONT, opt), telnet_cmd(NET_WONT, opt), TELNET_Q_NO, TELNET_Q_NO, 1, 1},
+		{"he stops", false, true, nil, telnet_cmd(NET_WILL, opt, NET_WONT, opt), telnet_cmd(NET_DO, opt, NET_DONT, opt), TELNET_Q_NO, TELNET_Q_NO, 1, 1},
+	}
+	for _, c := range cases {
+		conn := new_telnet_test_conn(opt, c.local, c.remote)
+		if c.start != nil {
+			c.start(conn.t)
+		}
+		conn.out = nil
+		conn.feed(c.in)
+		if !bytes.Equal(conn.out, c.out) {
+			t.Errorf("%s: sent %v, wanted %v", c.name, conn.out, c.out)
+		}
+		o := conn.t.options[opt]
+		if o.Us != c.us || o.Him != c.him {
+			t.Errorf("%s: us %d him %d, wanted us %d him %d", c.name, o.Us, o.Him, c.us, c.him)
+		}
+		if len(conn.on) != c.on || len(conn.off) != c.off {
+			t.Errorf("%s: enabled %d disabled %d times, wanted %d and %d", c.name, len(conn.on), len(conn.off), c.on, c.off)
+		}
+		if len(conn.data) != 0 {
+			t.Errorf("%s: negotiation leaked into the input: %v", c.name, conn.data)
+		}
+	}
+}
+
+// Two ends that each turn an option on and off as fast as they can settle
+// down, neither keeps the other talking forever.
+func TestTelnetNoLoop(t *testing.T) {
+	const opt = NET_NAWS
+	server := new_telnet_test_conn(opt, true, true)
+	client := new_telnet_test_conn(opt, true, true)
+	pump := func() int {
+		n := 0
+		for len(server.out) > 0 || len(client.out) > 0 {
+			if n++; n > 20 {
+				t.Fatal("still negotiating after 20 rounds")
+			}
+			s, c := server.out, client.out
+			server.out, client.out = nil, nil
+			client.feed(s)
+			server.feed(c)
+		}
+		return n
+	}
+	server.t.EnableLocal(opt)
+	client.t.EnableLocal(opt)
+	server.t.EnableRemote(opt)
+	client.t.EnableRemote(opt)
+	pump()
+	if !server.t.IsLocal(opt) || !server.t.IsRemote(opt) || !client.t.IsLocal(opt) || !client.t.IsRemote(opt) {
+		t.Error("both asked for it on both sides and it isn't on")
+	}
+	server.t.DisableLocal(opt)
+	server.t.EnableLocal(opt)
+	server.t.DisableRemote(opt)
+	pump()
+	if !server.t.IsLocal(opt) || server.t.IsRemote(opt) {
+		t.Errorf("server: local %v remote %v, wanted true false", server.t.IsLocal(opt), server.t.IsRemote(opt))
+	}
+	if client.t.IsLocal(opt) != server.t.IsRemote(opt) || client.t.IsRemote(opt) != server.t.IsLocal(opt) {
+		t.Error("the two ends don't agree")
+	}
+	// a client that keeps on saying WILL once it's on gets nothing back
+	server.t.EnableRemote(opt)
+	pump()
+	server.out = nil
+	for i := 0; i < 10; i++ {
+		server.feed(telnet_cmd(NET_WILL, opt))
+	}
+	if len(server.out) != 0 {
+		t.Errorf("answered a WILL it already agreed to: %v", server.out)
+	}
+}
+
+func TestTelnetFeed(t *testing.T) {
+	const opt = NET_GMCP
+	big := bytes.Repeat([]byte{'x'}, TELNET_SB_MAX+100)
+	cases := []struct {
+		name string
+		in   []byte
+		data []byte
+		subs [][]byte
+	}{
+		{"plain text", []byte("look\r\n"), []byte("look\r\n"), nil},
+		{"escaped 255", []byte{'a', NET_IAC, NET_IAC, 'b'}, []byte{'a', NET_IAC, 'b'}, nil},
+		{"commands swallowed", []byte{'a', NET_IAC, 241, 'b', NET_IAC, 249, 'c'}, []byte("abc"), nil},
+		{"subnegotiation", append(append([]byte{'a', NET_IAC, NET_SB, opt}, "Core.Hello {}"...), NET_IAC, NET_SE, 'b'),
+			[]byte("ab"), [][]byte{[]byte("Core.Hello {}")}},
+		{"IAC IAC inside SB", []byte{NET_IAC, NET_SB, opt, 1, NET_IAC, NET_IAC, 2, NET_IAC, NET_SE},
+			nil, [][]byte{{1, NET_IAC, 2}}},
+		{"SE without IAC is data", []byte{NET_IAC, NET_SB, opt, 1, NET_SE, 2, NET_IAC, NET_SE},
+			nil, [][]byte{{1, NET_SE, 2}}},
+		{"other option's SB", []byte{NET_IAC, NET_SB, NET_MSSP, 1, NET_IAC, NET_SE, 'a'}, []byte("a"), nil},
+		{"capped at TELNET_SB_MAX", append(append([]byte{NET_IAC, NET_SB, opt}, big...), NET_IAC, NET_SE, 'a'),
+			[]byte("a"), [][]byte{big[:TELNET_SB_MAX]}},
+		{"broken SB bails to a command", []byte{NET_IAC, NET_SB, opt, 1, NET_IAC, NET_WILL, NET_ECHO, 'a'},
+			[]byte("a"), nil},
+	}
+	for _, c := range cases {
+		conn := new_telnet_test_conn(opt, true, true)
+		conn.feed(c.in)
+		if !bytes.Equal(conn.data, c.data) {
+			t.Errorf("%s: input %q, wanted %q", c.name, conn.data, c.data)
+		}
+		if len(conn.subs) != len(c.subs) {
+			t.Errorf("%s: %d subnegotiations, wanted %d", c.name, len(conn.subs), len(c.subs))
+			continue
+		}
+		for i := range c.subs {
+			if !bytes.Equal(conn.subs[i], c.subs[i]) {
+				t.Errorf("%s: subnegotiation %q, wanted %q", c.name, conn.subs[i], c.subs[i])
+			}
+		}
+	}
+
+	// IAC split across reads picks up where it left off
+	conn := new_telnet_test_conn(opt, true, true)
+	conn.feed([]byte{'a', NET_IAC})
+	conn.feed([]byte{NET_IAC, 'b'})
+	if !bytes.Equal(conn.data, []byte{'a', NET_IAC, 'b'}) {
+		t.Errorf("split IAC IAC: input %v", conn.data)
+	}
+
+	// and the other way, what we send is escaped
+	conn.out = nil
+	conn.t.Subnegotiate(opt, []byte{1, NET_IAC, 2})
+	if want := []byte{NET_IAC, NET_SB, opt, 1, NET_IAC, NET_IAC, 2, NET_IAC, NET_SE}; !bytes.Equal(conn.out, want) {
+		t.Errorf("Subnegotiate sent %v, wanted %v", conn.out, want)
+	}
+}
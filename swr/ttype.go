@@ -0,0 +1,172 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	TTYPE_IS   = 0
+	TTYPE_SEND = 1
+)
+
+// MTTS bits, https://tintin.mudhalla.net/protocols/mtts/
+const (
+	MTTS_ANSI = 1 << iota
+	MTTS_VT100
+	MTTS_UTF8
+	MTTS_256_COLORS
+	MTTS_MOUSE_TRACKING
+	MTTS_OSC_COLOR_PALETTE
+	MTTS_SCREEN_READER
+	MTTS_PROXY
+	MTTS_TRUECOLOR
+	MTTS_MNES
+	MTTS_MSLP
+	MTTS_SSL
+)
+
+// TermCaps is what a client's terminal can do, as an MTTS bitvector.
+type TermCaps uint32
+
+// Everybody got 16 colors and UTF-8 before we asked, so that's what you get
+// until the client tells us otherwise.
+const TERM_CAPS_DEFAULT = TermCaps(MTTS_ANSI | MTTS_UTF8)
+
+func (t TermCaps) Ansi() bool {
+	return t&MTTS_ANSI != 0
+}
+
+func (t TermCaps) Color256() bool {
+	return t&(MTTS_256_COLORS|MTTS_TRUECOLOR) != 0
+}
+
+func (t TermCaps) TrueColor() bool {
+	return t&MTTS_TRUECOLOR != 0
+}
+
+func (t TermCaps) UTF8() bool {
+	return t&MTTS_UTF8 != 0
+}
+
+func (t TermCaps) ScreenReader() bool {
+	return t&MTTS_SCREEN_READER != 0
+}
+
+func (t TermCaps) String() string {
+	caps := make([]string, 0)
+	if t.Ansi() {
+		caps = append(caps, "ansi")
+	}
+	if t.TrueColor() {
+		caps = append(caps, "truecolor")
+	} else if t.Color256() {
+		caps = append(caps, "256color")
+	}
+	if t.UTF8() {
+		caps = append(caps, "utf-8")
+	}
+	if t.ScreenReader() {
+		caps = append(caps, "screenreader")
+	}
+	if len(caps) == 0 {
+		return "dumb"
+	}
+	return strings.Join(caps, " ")
+}
+
+// Guess what a terminal can do from its name, for clients that cycle TTYPE
+// but never get as far as MTTS. Unknown names get the defaults.
+func ttype_caps(name string) TermCaps {
+	name = strings.ToUpper(name)
+	switch {
+	case name == "DUMB" || name == "UNKNOWN":
+		return 0
+	case strings.Contains(name, "TRUECOLOR"), strings.Contains(name, "24BIT"), strings.Contains(name, "DIRECT"):
+		return TERM_CAPS_DEFAULT | MTTS_256_COLORS | MTTS_TRUECOLOR
+	case strings.Contains(name, "256COLOR"), name == "MUDLET", name == "MUSHCLIENT", name == "TINTIN++":
+		return TERM_CAPS_DEFAULT | MTTS_256_COLORS
+	case name == "VT100" || name == "VT102":
+		return MTTS_ANSI | MTTS_VT100
+	}
+	return TERM_CAPS_DEFAULT
+}
+
+// Hook TTYPE into a telnet session. We keep asking until the client repeats
+// itself (the end of its list) or hands us an MTTS bitvector. Round one is the
+// client name, round two the terminal type, round three MTTS.
+func ttype_telnet_init(t *Telnet, set func(TermCaps)) {
+	last := ""
+	t.Support(NET_TTYPE, false, true)
+	t.Handle(NET_TTYPE, &TelnetHandler{
+		OnEnable: func(local bool) {
+			if !local {
+				t.Subnegotiate(NET_TTYPE, []byte{TTYPE_SEND})
+			}
+		},
+		OnSub: func(data []byte) {
+			if len(data) < 2 || data[0] != TTYPE_IS {
+				return
+			}
+			name := strings.ToUpper(strings.TrimSpace(string(data[1:])))
+			if name == last {
+				return
+			}
+			last = name
+			if strings.HasPrefix(name, "MTTS ") {
+				if n, err := strconv.Atoi(strings.TrimSpace(name[5:])); err == nil {
+					set(TermCaps(n))
+				}
+				return
+			}
+			set(ttype_caps(name))
+			t.Subnegotiate(NET_TTYPE, []byte{TTYPE_SEND})
+		},
+	})
+	t.EnableRemote(NET_TTYPE)
+}
+
+// Box drawing and shading down to plain ASCII. One rune for one character so
+// boxes still line up.
+var ascii_replacer = strings.NewReplacer(
+	"┌", "+", "┐", "+", "└", "+", "┘", "+", "├", "+", "┤", "+", "┬", "+", "┴", "+", "┼", "+",
+	"╔", "+", "╗", "+", "╚", "+", "╝", "+", "╠", "+", "╣", "+", "╦", "+", "╩", "+", "╬", "+",
+	"╒", "+", "╕", "+", "╘", "+", "╛", "+", "╞", "+", "╡", "+", "╤", "+", "╧", "+", "╪", "+",
+	"╓", "+", "╖", "+", "╙", "+", "╜", "+", "╟", "+", "╢", "+", "╥", "+", "╨", "+", "╫", "+",
+	"─", "-", "═", "=", "│", "|", "║", "|",
+	"░", ".", "▒", ":", "▓", "#", "█", "#",
+	"♥", "*", "♡", "*",
+)
+
+// Fold a string down to ASCII for terminals that can't do UTF-8. Emoji and
+// other symbols are dropped, anything else we don't know becomes a ?.
+func ascii_fold(str string) string {
+	str = ascii_replacer.Replace(str)
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 {
+			return r
+		}
+		if unicode.Is(unicode.So, r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Sk, r) {
+			return -1
+		}
+		return '?'
+	}, str)
+}
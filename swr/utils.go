@@ -185,7 +185,6 @@ func consolify(str string) string {
 	return buf
 }
 
-//lint:ignore U1000 useful code
 func slice_contains_string(slice []string, value string) bool {
 	for _, s := range slice {
 		if strings.EqualFold(s, value) {
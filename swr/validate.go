@@ -0,0 +1,175 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Boot time checks of the world. Problems found while loading (a file that
+// won't parse, a field it doesn't know) are collected, and once everything is
+// loaded the fatal ones either stop the boot or get their file moved out of
+// the way into data/quarantine, depending on on_load_error in config.yml. The
+// rest are only logged, and so is whatever points at something that isn't
+// there (an area resetting a mob that doesn't exist, an exit into nothing),
+// that's only skipped. It's often the other file that's broken.
+
+const (
+	LOAD_ERROR_QUARANTINE = "quarantine" // the default
+	LOAD_ERROR_REFUSE     = "refuse"
+)
+
+var load_problems []*LoadError
+
+func load_problem(err error) {
+	if err == nil {
+		return
+	}
+	var le *LoadError
+	if !errors.As(err, &le) {
+		le = &LoadError{Err: err, Fatal: true}
+	}
+	load_problems = append(load_problems, le)
+}
+
+// Deals with everything that went wrong loading, then cross checks what's
+// left.
+func (d *GameDatabase) Validate() {
+	fatal := make([]*LoadError, 0)
+	for _, p := range load_problems {
+		if p.Fatal {
+			fatal = append(fatal, p)
+			Log(LOG_DB).Error("load error", "err", p)
+		} else {
+			Log(LOG_DB).Warn("load problem", "err", p)
+		}
+	}
+	load_problems = nil
+	if len(fatal) == 0 {
+		d.validate_resets()
+		d.validate_exits()
+		return
+	}
+	if strings.EqualFold(Config().OnLoadError, LOAD_ERROR_REFUSE) {
+		Log(LOG_DB).Error(fmt.Sprintf("%d fatal problems with the game data, refusing to start", len(fatal)))
+		os.Exit(1)
+	}
+	done := make(map[string]bool)
+	for _, p := range fatal {
+		if p.File == "" || done[p.File] {
+			continue
+		}
+		done[p.File] = true
+		d.unload(p.File)
+		quarantine(p.File)
+	}
+	d.validate_resets()
+	d.validate_exits()
+}
+
+// Takes whatever came out of the file back out of the world.
+func (d *GameDatabase) unload(file string) {
+	d.Lock()
+	defer d.Unlock()
+	for name, area := range d.areas {
+		if area.Filename != file {
+			continue
+		}
+		for _, r := range area.Rooms {
+			if room, ok := d.rooms[r.Id]; ok && room.Area == area {
+				delete(d.rooms, r.Id)
+			}
+		}
+		delete(d.areas, name)
+	}
+	for id, item := range d.items {
+		if item.Filename == file {
+			delete(d.items, id)
+		}
+	}
+	for id, mob := range d.mobs {
+		if mob.Filename == file {
+			delete(d.mobs, id)
+		}
+	}
+}
+
+// Moves a broken file to data/quarantine so it's out of the game but still
+// around to be fixed.
+func quarantine(file string) {
+	if !file_exists(file) {
+		return
+	}
+	rel := strings.TrimPrefix(filepath.ToSlash(file), "data/")
+	dest := filepath.Join("data/quarantine", sprintf("%s.%s", rel, time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		Log(LOG_DB).Error("can't quarantine file", "file", file, "err", err)
+		return
+	}
+	if err := os.Rename(file, dest); err != nil {
+		Log(LOG_DB).Error("can't quarantine file", "file", file, "err", err)
+		return
+	}
+	Log(LOG_DB).Warn("quarantined file", "file", file, "to", dest)
+}
+
+// Resets of mobs, items or rooms that aren't there, the reset skips them.
+func (d *GameDatabase) validate_resets() {
+	d.RLock()
+	defer d.RUnlock()
+	for _, area := range d.areas {
+		for i, spawn := range area.Mobs {
+			if _, ok := d.mobs[spawn.Mob]; !ok {
+				Log(LOG_DB).Warn("load problem", "err", &LoadError{File: area.Filename, Field: fmt.Sprintf("mobs[%d].mob", i), Err: Err("mob %d doesn't exist", spawn.Mob)})
+			}
+			if _, ok := d.rooms[spawn.Room]; !ok {
+				Log(LOG_DB).Warn("load problem", "err", &LoadError{File: area.Filename, Field: fmt.Sprintf("mobs[%d].room", i), Err: Err("room %d doesn't exist", spawn.Room)})
+			}
+		}
+		for i, spawn := range area.Items {
+			if _, ok := d.items[spawn.Item]; !ok {
+				Log(LOG_DB).Warn("load problem", "err", &LoadError{File: area.Filename, Field: fmt.Sprintf("items[%d].item", i), Err: Err("item %d doesn't exist", spawn.Item)})
+			}
+			if _, ok := d.rooms[spawn.Room]; !ok {
+				Log(LOG_DB).Warn("load problem", "err", &LoadError{File: area.Filename, Field: fmt.Sprintf("items[%d].room", i), Err: Err("room %d doesn't exist", spawn.Room)})
+			}
+		}
+	}
+}
+
+// Exits into nothing, not worth stopping the boot for.
+func (d *GameDatabase) validate_exits() {
+	d.RLock()
+	defer d.RUnlock()
+	for _, room := range d.rooms {
+		for dir, to := range room.Exits {
+			if _, ok := d.rooms[to]; !ok {
+				file := ""
+				if room.Area != nil {
+					file = room.Area.Filename
+				}
+				Log(LOG_DB).Warn("load problem", "err", &LoadError{File: file, Field: sprintf("room %d exits.%s", room.Id, dir), Err: Err("room %d doesn't exist", to)})
+			}
+		}
+	}
+}
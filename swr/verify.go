@@ -0,0 +1,274 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// `swr verify` runs every file under data/ through load and save, the same
+// way the game does, and checks nothing was lost or changed on the way.
+// Something that comes back different is a field the structs don't know
+// about, or one they write back some other way, and the game would do the
+// same to the file the next time it saved it.
+
+// What's in each directory under data/, the first prefix that matches wins.
+var verify_kinds = []struct {
+	prefix string
+	new    func() interface{}
+}{
+	{"data/ships/prototypes/", func() interface{} { return new(ShipData) }},
+	{"data/ships/", func() interface{} { return new(ShipData) }},
+	{"data/areas/", func() interface{} { return new(AreaData) }},
+	{"data/items/", func() interface{} { return new(ItemData) }},
+	{"data/mobs/", func() interface{} { return new(CharData) }},
+	{"data/planets/", func() interface{} { return new(StarSystemData) }},
+	{"data/races/", func() interface{} { return new(RaceData) }},
+	{"data/languages/", func() interface{} { return new(Language) }},
+	{"data/accounts/", func() interface{} { return new(PlayerProfile) }},
+	{"data/world.yml", func() interface{} { return new(WorldState) }},
+	{"data/sys/commands.yml", func() interface{} { return &[]*Command{} }},
+	{"data/sys/config.yml", func() interface{} { return new(Configuration) }},
+}
+
+// Checks all of data/, returns what the process should exit with.
+func Verify() int {
+	tmp, err := os.MkdirTemp("", "swr-verify")
+	if err != nil {
+		Log(LOG_DB).Error("can't make a temp dir", "err", err)
+		return 1
+	}
+	defer os.RemoveAll(tmp)
+	files, bad, skipped := 0, 0, 0
+	err = filepath.Walk("data", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		path = filepath.ToSlash(path)
+		if info.IsDir() {
+			if path == "data/quarantine" || path == "data/logs" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".yml") && !strings.HasSuffix(path, ".yaml") {
+			return nil
+		}
+		new := verify_kind(path)
+		if new == nil {
+			skipped++
+			fmt.Printf("%s: skipped, don't know what's in it\n", path)
+			return nil
+		}
+		files++
+		if diffs := verify_file(path, filepath.Join(tmp, fmt.Sprintf("%d.yml", files)), new); len(diffs) > 0 {
+			bad++
+			for _, d := range diffs {
+				fmt.Printf("%s: %s\n", path, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		Log(LOG_DB).Error("can't walk data", "err", err)
+		return 1
+	}
+	fmt.Printf("%d files checked, %d with differences, %d skipped.\n", files, bad, skipped)
+	if bad > 0 {
+		return 1
+	}
+	return 0
+}
+
+// What to load a file under data/ into, nil if we don't know.
+func verify_kind(path string) func() interface{} {
+	for _, k := range verify_kinds {
+		if strings.HasPrefix(path, k.prefix) {
+			return k.new
+		}
+	}
+	return nil
+}
+
+// Loads path, saves it to tmp and loads that again. What changed, if anything.
+func verify_file(path string, tmp string, new func() interface{}) []string {
+	first := new()
+	if err := load_yaml(path, first); err != nil {
+		return []string{err.Error()}
+	}
+	if err := write_yaml(tmp, first); err != nil {
+		return []string{"save: " + err.Error()}
+	}
+	second := new()
+	if err := load_yaml(tmp, second); err != nil {
+		return []string{"reload: " + err.Error()}
+	}
+	diffs := make([]string, 0)
+	// saving it again has to come out the same, an empty list and no list
+	// are the same thing on disk so this is as close as we care
+	a, _ := marshal_yaml(first)
+	b, _ := marshal_yaml(second)
+	if !bytes.Equal(a, b) {
+		diffs = append(diffs, "saves differently after a save and load")
+	}
+	before, err := verify_generic(path)
+	if err != nil {
+		return append(diffs, err.Error())
+	}
+	after, err := verify_generic(tmp)
+	if err != nil {
+		return append(diffs, "reload: "+err.Error())
+	}
+	verify_diff("", before, after, &diffs)
+	return diffs
+}
+
+// The file as plain maps, lists and strings with the empty stuff left out,
+// since empty and missing load the same.
+func verify_generic(path string) (interface{}, error) {
+	fp, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(fp, &v); err != nil {
+		return nil, err
+	}
+	return verify_normalize(v), nil
+}
+
+func verify_normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		ret := make(map[string]interface{})
+		for k, e := range t {
+			if n := verify_normalize(e); n != nil {
+				ret[k] = n
+			}
+		}
+		if len(ret) == 0 {
+			return nil
+		}
+		return ret
+	case map[interface{}]interface{}:
+		// keys that aren't all strings, like orbits: {1: ...}
+		m := make(map[string]interface{})
+		for k, e := range t {
+			m[fmt.Sprint(k)] = e
+		}
+		return verify_normalize(m)
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+		ret := make([]interface{}, len(t))
+		for i, e := range t {
+			ret[i] = verify_normalize(e)
+		}
+		return ret
+	case float64:
+		if t == 0 {
+			return nil
+		}
+		return strconv.FormatFloat(t, 'g', -1, 64)
+	default:
+		// 5 and "5" and 5.0 are the same thing to a struct field
+		s := fmt.Sprint(t)
+		if s == "" || s == "0" || s == "false" {
+			return nil
+		}
+		return s
+	}
+}
+
+func verify_diff(path string, a interface{}, b interface{}, diffs *[]string) {
+	name := path
+	if name == "" {
+		name = "(file)"
+	}
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s was a map, now %s", name, verify_show(b)))
+			return
+		}
+		keys := make([]string, 0, len(at)+len(bt))
+		for k := range at {
+			keys = append(keys, k)
+		}
+		for k := range bt {
+			if _, ok := at[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field := k
+			if path != "" {
+				field = path + "." + k
+			}
+			verify_diff(field, at[k], bt[k], diffs)
+		}
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s was a list, now %s", name, verify_show(b)))
+			return
+		}
+		if len(at) != len(bt) {
+			*diffs = append(*diffs, fmt.Sprintf("%s had %d entries, now %d", name, len(at), len(bt)))
+			return
+		}
+		for i := range at {
+			verify_diff(fmt.Sprintf("%s[%d]", path, i), at[i], bt[i], diffs)
+		}
+	default:
+		if !reflect.DeepEqual(a, b) {
+			*diffs = append(*diffs, fmt.Sprintf("%s was %s, now %s", name, verify_show(a), verify_show(b)))
+		}
+	}
+}
+
+func verify_show(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "gone"
+	case map[string]interface{}:
+		return "a map"
+	case []interface{}:
+		return "a list"
+	case string:
+		if len(t) > 40 {
+			t = t[:40] + "..."
+		}
+		return strconv.Quote(t)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,310 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// The browser side of things. Text frames are game output (ANSI, or HTML when
+// the page asks for ?mode=html), binary frames are out-of-band JSON like GMCP.
+// Frames from the browser are lines of input.
+
+var web_resize = regexp.MustCompile("^\x1b\\[8;([0-9]+);([0-9]+)t$")
+
+var web_upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+type WebClient struct {
+	Id      string
+	Con     *websocket.Conn
+	addr    string
+	html    bool
+	wm      *sync.Mutex
+	pending []string
+	width   int32
+	caps    TermCaps
+	input   *InputQueue
+	closed  int32 // atomic, the game loop and the connection both look
+	idle    int32
+	editing int32
+	EditPtr *string
+	Queue   []string
+}
+
+func NewWebClient(con *websocket.Conn, addr string, html bool) *WebClient {
+	client := new(WebClient)
+	client.Id = hex.EncodeToString([]byte("ws:" + con.RemoteAddr().String()))
+	client.Con = con
+	client.addr = addr
+	client.html = html
+	client.wm = &sync.Mutex{}
+	client.pending = make([]string, 0)
+	client.input = NewInputQueue()
+	client.width = TERM_WIDTH_DEFAULT
+	// xterm.js and friends can do anything, the html renderer only knows 16
+	client.caps = TERM_CAPS_DEFAULT | MTTS_256_COLORS | MTTS_TRUECOLOR
+	if html {
+		client.caps = TERM_CAPS_DEFAULT
+	}
+	return client
+}
+
+func (c *WebClient) write(kind int, buffer []byte) {
+	c.wm.Lock()
+	err := c.Con.WriteMessage(kind, buffer)
+	c.wm.Unlock()
+	if err != nil {
+		c.Close()
+	}
+}
+
+func (c *WebClient) Send(str string) {
+	str = Color().Render(str, c.caps)
+	if c.html {
+		str = Color().Htmlize(str)
+	}
+	if c.IsEditing() {
+		c.Queue = append(c.Queue, str)
+	} else {
+		c.write(websocket.TextMessage, []byte(str))
+	}
+}
+
+func (c *WebClient) Sendf(format string, any ...interface{}) {
+	c.Send(fmt.Sprintf(format, any...))
+}
+
+func (c *WebClient) Flush() {}
+
+func (c *WebClient) Raw(buffer []byte) {
+	if c.html {
+		buffer = []byte(html.EscapeString(string(buffer)))
+	}
+	c.write(websocket.TextMessage, buffer)
+}
+
+func (c *WebClient) ReadRaw(b []byte) (int, error) {
+	_, msg, err := c.Con.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	return copy(b, msg), nil
+}
+
+// Read returns the next line typed in the browser. A frame can carry more than
+// one line if somebody pastes, so extra lines wait in pending.
+func (c *WebClient) Read() string {
+	for {
+		if c.IsClosed() || c.IsEditing() {
+			return ""
+		}
+		if len(c.pending) > 0 {
+			line := c.pending[0]
+			c.pending = c.pending[1:]
+			// the page reports its size like xterm does, ESC [ 8 ; rows ; cols t
+			if m := web_resize.FindStringSubmatch(line); m != nil {
+				cols, _ := strconv.Atoi(m[2])
+				atomic.StoreInt32(&c.width, int32(term_width(cols)))
+				continue
+			}
+			return strings.TrimSpace(line)
+		}
+		_, msg, err := c.Con.ReadMessage()
+		if err != nil {
+			c.Close()
+			return ""
+		}
+		text := strings.ReplaceAll(string(msg), "\r\n", "\n")
+		c.pending = append(c.pending, strings.Split(strings.TrimSuffix(text, "\n"), "\n")...)
+	}
+}
+
+func (c *WebClient) Close() {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return
+	}
+	c.wm.Lock()
+	c.Con.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.wm.Unlock()
+	c.Con.Close()
+}
+
+func (c *WebClient) IsClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+func (c *WebClient) BufferEditor(str *string) {
+	if !c.IsEditing() {
+		c.EditPtr = str
+		c.SetEditing(true)
+	}
+}
+
+func (c *WebClient) GetId() string {
+	return c.Id
+}
+
+func (c *WebClient) SetEditing(editing bool) {
+	atomic.StoreInt32(&c.editing, bool_int32(editing))
+}
+
+func (c *WebClient) IsEditing() bool {
+	return atomic.LoadInt32(&c.editing) == 1
+}
+
+func (c *WebClient) IdleInc() {
+	atomic.AddInt32(&c.idle, 1)
+}
+
+func (c *WebClient) GetIdle() int {
+	return int(atomic.LoadInt32(&c.idle))
+}
+
+func (c *WebClient) IdleReset() {
+	atomic.StoreInt32(&c.idle, 0)
+}
+
+func (c *WebClient) SendQueue() {
+	for _, s := range c.Queue {
+		c.write(websocket.TextMessage, []byte(s))
+	}
+}
+
+func (c *WebClient) ClearQueue() {
+	c.Queue = make([]string, 0)
+}
+
+func (c *WebClient) GetWidth() int {
+	return int(atomic.LoadInt32(&c.width))
+}
+
+func (c *WebClient) GetCaps() TermCaps {
+	return c.caps
+}
+
+func (c *WebClient) GetAddr() string {
+	return c.addr
+}
+
+func (c *WebClient) Input() *InputQueue {
+	return c.input
+}
+
+func (c *WebClient) Telnet() *Telnet {
+	return nil
+}
+
+func (c *WebClient) SendGMCP(pkg string, data interface{}) {
+	c.oob(map[string]interface{}{"gmcp": pkg, "data": data})
+}
+
+// The page turns the input box into a password box while echo is off.
+func (c *WebClient) SetEcho(echo bool) {
+	c.oob(map[string]interface{}{"echo": echo})
+}
+
+func (c *WebClient) oob(data interface{}) {
+	if c.IsClosed() {
+		return
+	}
+	msg, err := json.Marshal(data)
+	if err != nil {
+		ErrorCheck(err)
+		return
+	}
+	c.write(websocket.BinaryMessage, msg)
+}
+
+func web_trusted_proxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, site := range Config().Proxies {
+		if cidr, ok := ban_cidr(site); ok && cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Where the player really is. Behind a trusted proxy that's the last hop in
+// X-Forwarded-For that isn't one of our proxies (anything before it the
+// player could have made up), or X-Real-IP. Anyone else gets their socket.
+func web_remote_addr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !web_trusted_proxy(host) {
+		return host
+	}
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+		hops := strings.Split(strings.Join(fwd, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !web_trusted_proxy(hop) {
+				return hop
+			}
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return host
+}
+
+// Serves the bundled web client out of data/web and the websocket on /ws.
+func WebServerStart(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("data/web")))
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		con, err := web_upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			Log(LOG_NET).Warn("error upgrading websocket", "addr", r.RemoteAddr, "err", err)
+			return
+		}
+		client := NewWebClient(con, web_remote_addr(r), r.URL.Query().Get("mode") == "html")
+		Log(LOG_NET).Info("connection accepted", "addr", client.GetAddr(), "via", "websocket")
+		if ban_check_site(client) {
+			return
+		}
+		client_session(client, auth_do_welcome)
+	})
+	Log(LOG_NET).Info("listening", "addr", addr, "via", "websocket")
+	err := http.ListenAndServe(addr, mux)
+	ErrorCheck(err)
+}
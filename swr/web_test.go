@@ -0,0 +1,59 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWebRemoteAddr(t *testing.T) {
+	orig := Config().Proxies
+	defer func() { Config().Proxies = orig }()
+	Config().Proxies = []string{"127.0.0.1", "10.0.0.0/8"}
+
+	cases := []struct {
+		name   string
+		remote string
+		fwd    string
+		real   string
+		want   string
+	}{
+		{"no proxy", "203.0.113.5:4000", "", "", "203.0.113.5"},
+		{"untrusted says it's somebody else", "203.0.113.5:4000", "198.51.100.1", "198.51.100.2", "203.0.113.5"},
+		{"trusted forwarded", "127.0.0.1:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"forged hop before ours", "127.0.0.1:4000", "6.6.6.6, 198.51.100.1", "", "198.51.100.1"},
+		{"through two of ours", "127.0.0.1:4000", "198.51.100.1, 10.1.2.3", "", "198.51.100.1"},
+		{"real ip", "10.1.2.3:4000", "", "198.51.100.2", "198.51.100.2"},
+		{"garbage", "127.0.0.1:4000", "nonsense", "also nonsense", "127.0.0.1"},
+		{"ipv6", "[2001:db8::1]:4000", "198.51.100.1", "", "2001:db8::1"},
+	}
+	for _, c := range cases {
+		r, _ := http.NewRequest("GET", "/ws", nil)
+		r.RemoteAddr = c.remote
+		if c.fwd != "" {
+			r.Header.Set("X-Forwarded-For", c.fwd)
+		}
+		if c.real != "" {
+			r.Header.Set("X-Real-IP", c.real)
+		}
+		if got := web_remote_addr(r); got != c.want {
+			t.Errorf("%s: got %s, wanted %s", c.name, got, c.want)
+		}
+	}
+}
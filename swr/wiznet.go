@@ -0,0 +1,129 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"strings"
+)
+
+// Wiznet lets immortals watch the server from inside the game. Log lines sent
+// out on a channel (see [Logger.Wiz]) show up for everyone subscribed to it,
+// and every error goes out on the errors channel.
+
+const (
+	WIZ_LOGINS = "logins" // players coming and going, linkdead
+	WIZ_DEATHS = "deaths"
+	WIZ_PROGS  = "progs" // mudprog errors
+	WIZ_SAVES  = "saves"
+	WIZ_RESETS = "resets"
+	WIZ_BANS   = "bans"
+	WIZ_ERRORS = "errors"
+)
+
+var wiznet_channels = []string{WIZ_LOGINS, WIZ_DEATHS, WIZ_PROGS, WIZ_SAVES, WIZ_RESETS, WIZ_BANS, WIZ_ERRORS}
+
+type wiznet_msg struct {
+	channel string
+	level   LogLevel
+	msg     string
+}
+
+// Lines are logged from any goroutine, they wait here for the game loop.
+var wiznet_queue = make(chan wiznet_msg, 256)
+
+func wiznet(channel string, level LogLevel, msg string) {
+	select {
+	case wiznet_queue <- wiznet_msg{channel: channel, level: level, msg: msg}:
+	default: // nobody's draining it (boot), or it's a flood, either way it's in the log
+	}
+}
+
+func (p *PlayerProfile) WiznetOn(channel string) bool {
+	return slice_contains_string(p.Wiznet, channel)
+}
+
+// Game loop only.
+func processWiznet() {
+	for n := len(wiznet_queue); n > 0; n-- {
+		m := <-wiznet_queue
+		color := "&w"
+		switch m.level {
+		case LOG_WARN:
+			color = "&Y"
+		case LOG_ERROR:
+			color = "&R"
+		}
+		for _, e := range DB().Entities() {
+			if e == nil || !e.IsPlayer() {
+				continue
+			}
+			player := e.(*PlayerProfile)
+			if player.Client == nil || player.Priv < 100 || !player.WiznetOn(m.channel) {
+				continue
+			}
+			player.Send("\r\n&x[&Cwiznet&x:&W%s&x] %s%s&d\r\n", m.channel, color, m.msg)
+		}
+	}
+}
+
+func do_wiznet(entity Entity, args ...string) {
+	if entity == nil || !entity.IsPlayer() {
+		return
+	}
+	player := entity.(*PlayerProfile)
+	if len(args) == 0 || args[0] == "" {
+		entity.Send("\r\n%s\r\n", MakeTitle("Wiznet", ANSI_TITLE_STYLE_NORMAL, ANSI_TITLE_ALIGNMENT_CENTER, entity_width(entity)))
+		for _, c := range wiznet_channels {
+			state := "&xoff"
+			if player.WiznetOn(c) {
+				state = "&Gon"
+			}
+			entity.Send("&W%-10s %s&d\r\n", c, state)
+		}
+		entity.Send("&wType &Wwiznet <channel>&w to turn one on or off, or &Wwiznet all&w / &Wwiznet none&w.&d\r\n")
+		return
+	}
+	channel := strings.ToLower(args[0])
+	switch channel {
+	case "all":
+		player.Wiznet = append([]string{}, wiznet_channels...)
+		entity.Send("\r\n&GYou are listening to every wiznet channel.&d\r\n")
+		return
+	case "none":
+		player.Wiznet = nil
+		entity.Send("\r\n&GWiznet is off.&d\r\n")
+		return
+	}
+	if !slice_contains_string(wiznet_channels, channel) {
+		entity.Send("\r\n&RThere is no wiznet channel &W%s&R, there's %s.&d\r\n", channel, strings.Join(wiznet_channels, ", "))
+		return
+	}
+	if player.WiznetOn(channel) {
+		keep := make([]string, 0, len(player.Wiznet))
+		for _, c := range player.Wiznet {
+			if !strings.EqualFold(c, channel) {
+				keep = append(keep, c)
+			}
+		}
+		player.Wiznet = keep
+		entity.Send("\r\n&GWiznet &W%s&G is off.&d\r\n", channel)
+		return
+	}
+	player.Wiznet = append(player.Wiznet, channel)
+	entity.Send("\r\n&GWiznet &W%s&G is on.&d\r\n", channel)
+}
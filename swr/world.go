@@ -0,0 +1,190 @@
+/*  Star Wars Role-Playing Mud
+ *  Copyright (C) 2022 @{See Authors}
+ *
+ *  This program is free software: you can redistribute it and/or modify
+ *  it under the terms of the GNU General Public License as published by
+ *  the Free Software Foundation, either version 3 of the License, or
+ *  (at your option) any later version.
+ *
+ *  This program is distributed in the hope that it will be useful,
+ *  but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *  GNU General Public License for more details.
+ *
+ *  You should have received a copy of the GNU General Public License
+ *  along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+package swr
+
+import (
+	"sort"
+	"time"
+)
+
+// The world as players left it. Every save takes a snapshot of what's lying
+// around in the rooms (containers and corpses with whatever is in them), the
+// doors, and the room flags listed under world in config.yml, and after the
+// areas reset at boot the snapshot is put back over them. Things left on the
+// floor crumble away after a while (world.expire), unless the room is
+// flagged storage.
+
+const (
+	ROOM_FLAG_STORAGE = "storage"
+	WORLD_EXPIRE      = 24 * 60 * 60 // seconds, unless config.yml says otherwise
+)
+
+type WorldConfig struct {
+	Expire uint     `yaml:"expire,omitempty"`     // seconds dropped items last outside storage rooms, 0 for the default
+	Flags  []string `yaml:"flags,flow,omitempty"` // room flags that change in play and are kept over a reboot
+}
+
+type WorldState struct {
+	Saved time.Time    `yaml:"saved"`
+	Rooms []*RoomState `yaml:"rooms,omitempty"`
+}
+
+type RoomState struct {
+	Room    uint                     `yaml:"room"`
+	Flags   []string                 `yaml:"flags,flow,omitempty"` // which of world.flags are set
+	Doors   map[string]*RoomExitFlag `yaml:"doors,omitempty"`
+	Items   []*ItemData              `yaml:"items,omitempty"`
+	Dropped map[uint]time.Time       `yaml:"dropped,omitempty"` // by item id, missing for the ones the resets put there
+}
+
+func world_expire_after() time.Duration {
+	if Config().World.Expire > 0 {
+		return time.Duration(Config().World.Expire) * time.Second
+	}
+	return WORLD_EXPIRE * time.Second
+}
+
+// Has the item been lying here long enough to go?
+func (r *RoomData) ItemExpired(item Item, now time.Time) bool {
+	return r.expired(r.dropped[item.GetId()], now)
+}
+
+func (r *RoomData) expired(dropped time.Time, now time.Time) bool {
+	return !dropped.IsZero() && !r.HasFlag(ROOM_FLAG_STORAGE) && now.Sub(dropped) > world_expire_after()
+}
+
+// The rooms worth remembering: anything lying in them, their doors, and the
+// world.flags when they aren't what the area says. Called from Save with the
+// lock held.
+func (d *GameDatabase) world_state() *WorldState {
+	ws := &WorldState{Saved: time.Now(), Rooms: make([]*RoomState, 0)}
+	templates := make(map[uint]*RoomData)
+	for _, area := range d.areas {
+		for i := range area.Rooms {
+			templates[area.Rooms[i].Id] = &area.Rooms[i]
+		}
+	}
+	ids := make([]uint, 0, len(d.rooms))
+	for id := range d.rooms {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		room := d.rooms[id]
+		rs := &RoomState{Room: id}
+		changed := false
+		for _, item := range room.Items {
+			if item == nil {
+				continue
+			}
+			rs.Items = append(rs.Items, item.GetData())
+			if t, ok := room.dropped[item.GetId()]; ok {
+				if rs.Dropped == nil {
+					rs.Dropped = make(map[uint]time.Time)
+				}
+				rs.Dropped[item.GetId()] = t
+			}
+			changed = true
+		}
+		for dir, f := range room.ExitFlags {
+			if f == nil {
+				continue
+			}
+			if rs.Doors == nil {
+				rs.Doors = make(map[string]*RoomExitFlag)
+			}
+			rs.Doors[dir] = &RoomExitFlag{Closed: f.Closed, Locked: f.Locked}
+			changed = true
+		}
+		template := templates[id]
+		for _, flag := range Config().World.Flags {
+			if room.HasFlag(flag) {
+				rs.Flags = append(rs.Flags, flag)
+			}
+			if template == nil || room.HasFlag(flag) != template.HasFlag(flag) {
+				changed = true
+			}
+		}
+		if changed {
+			ws.Rooms = append(ws.Rooms, rs)
+		}
+	}
+	return ws
+}
+
+// Puts the world back the way it was saved, after the areas have reset.
+func (d *GameDatabase) RestoreWorld() {
+	ws, err := Storage().LoadWorld()
+	if err != nil {
+		Log(LOG_DB).Error("can't read the world state, starting from the resets", "err", err)
+		return
+	}
+	if ws != nil {
+		now := time.Now()
+		rooms, items, expired := 0, 0, 0
+		for _, rs := range ws.Rooms {
+			room := d.GetRoom(rs.Room, 0)
+			if room == nil {
+				Log(LOG_DB).Warn("world state for a room that's gone", "room", rs.Room)
+				continue
+			}
+			rooms++
+			for _, flag := range Config().World.Flags {
+				if slice_contains_string(rs.Flags, flag) {
+					room.SetFlag(flag)
+				} else {
+					room.RemoveFlag(flag)
+				}
+			}
+			for dir, door := range rs.Doors {
+				if f, ok := room.ExitFlags[dir]; ok && f != nil {
+					f.Closed = door.Closed
+					f.Locked = door.Locked
+				}
+			}
+			room.Items = make([]Item, 0, len(rs.Items))
+			room.dropped = nil
+			for _, item := range rs.Items {
+				dropped := rs.Dropped[item.Id]
+				if room.expired(dropped, now) {
+					expired++
+					continue
+				}
+				room.PlaceItem(item, dropped)
+				items++
+			}
+		}
+		Log(LOG_DB).Info("world restored", "saved", ws.Saved.Format(time.RFC3339), "rooms", rooms, "items", items, "expired", expired)
+	}
+	ScheduleFunc(world_expire, true, 60)
+}
+
+// Clears away what's been lying around too long. Scheduled, so on the game
+// loop.
+func world_expire() {
+	now := time.Now()
+	for _, room := range DB().Rooms() {
+		for _, item := range room.Items {
+			if item == nil || !room.ItemExpired(item, now) {
+				continue
+			}
+			room.RemoveItem(item)
+			room.SendToRoom(sprintf("\r\n&x%s crumbles away.&d\r\n", item.GetData().Name))
+		}
+	}
+}